@@ -0,0 +1,265 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package diag collects the errors and warnings found while generating from
+// a set of proto files, located at the definitions they're about.
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Severity tells whether a diagnostic is an error or a warning.
+type Severity int
+
+const (
+	// Warning diagnostics are about output which was generated, but is
+	// likely wrong, such as a mock holding a placeholder.
+	Warning Severity = iota
+	// Error diagnostics are about output which couldn't be generated.
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is an error or a warning about a definition.
+type Diagnostic struct {
+	Severity Severity
+	// File is the name of the proto file holding the definition, or "" if
+	// the diagnostic isn't about a definition.
+	File string
+	// Line and Column locate the definition within File, counting from 1.
+	// They're 0 if the file has no source code info.
+	Line, Column int
+	Message      string
+}
+
+// String formats d the way protoc formats its errors, prefixed with its
+// location.
+func (d Diagnostic) String() string {
+	var location string
+	switch {
+	case d.File != "" && d.Line > 0:
+		location = fmt.Sprintf("%s:%d:%d: ", d.File, d.Line, d.Column)
+	case d.File != "":
+		location = d.File + ": "
+	}
+	return fmt.Sprintf("%s%s: %s", location, d.Severity, d.Message)
+}
+
+// position is the location of a definition.
+type position struct {
+	file         string
+	line, column int
+}
+
+// Diagnostics collects the diagnostics about the definitions of a set of
+// files. A nil *Diagnostics discards what's reported to it.
+type Diagnostics struct {
+	positions map[interface{}]position
+	list      []Diagnostic
+	seen      map[Diagnostic]bool
+}
+
+// New returns an empty set of diagnostics about the definitions of files.
+func New(files []*descriptor.FileDescriptorProto) *Diagnostics {
+	d := &Diagnostics{
+		positions: map[interface{}]position{},
+		seen:      map[Diagnostic]bool{},
+	}
+	for _, file := range files {
+		d.addFile(file)
+	}
+	return d
+}
+
+// Errorf reports an error about def, which is one of the descriptors of the
+// files, or nil.
+func (d *Diagnostics) Errorf(def interface{}, format string, args ...interface{}) {
+	d.report(Error, def, format, args...)
+}
+
+// Warnf reports a warning about def, which is one of the descriptors of the
+// files, or nil.
+func (d *Diagnostics) Warnf(def interface{}, format string, args ...interface{}) {
+	d.report(Warning, def, format, args...)
+}
+
+func (d *Diagnostics) report(severity Severity, def interface{}, format string, args ...interface{}) {
+	if d == nil {
+		return
+	}
+	pos := d.positions[def]
+	diagnostic := Diagnostic{
+		Severity: severity,
+		File:     pos.file,
+		Line:     pos.line,
+		Column:   pos.column,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	// The same definition is often visited once per output format, so it's
+	// only reported once.
+	if d.seen[diagnostic] {
+		return
+	}
+	d.seen[diagnostic] = true
+	d.list = append(d.list, diagnostic)
+}
+
+// List returns the diagnostics in the order they were reported.
+func (d *Diagnostics) List() []Diagnostic {
+	if d == nil {
+		return nil
+	}
+	return d.list
+}
+
+// Count returns the number of errors and warnings reported.
+func (d *Diagnostics) Count() (errors, warnings int) {
+	for _, diagnostic := range d.List() {
+		if diagnostic.Severity == Error {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// Summary returns every diagnostic on its own line, followed by the number
+// of errors and warnings, or "" if there are none.
+func (d *Diagnostics) Summary() string {
+	if len(d.List()) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, diagnostic := range d.List() {
+		b.WriteString(diagnostic.String())
+		b.WriteString("\n")
+	}
+	errors, warnings := d.Count()
+	fmt.Fprintf(&b, "%s, %s", plural(errors, "error"), plural(warnings, "warning"))
+	return b.String()
+}
+
+// WriteSummary writes the summary of the diagnostics to w, if there are any.
+func (d *Diagnostics) WriteSummary(w io.Writer) error {
+	summary := d.Summary()
+	if summary == "" {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Field numbers of the descriptor messages, which make up source code info
+// paths.
+const (
+	fileMessagePath      = 4
+	fileEnumPath         = 5
+	fileServicePath      = 6
+	messageFieldPath     = 2
+	messageNestedPath    = 3
+	messageEnumPath      = 4
+	enumValuePath        = 2
+	serviceMethodPath    = 2
+	messageExtensionPath = 6
+	fileExtensionPath    = 7
+)
+
+// addFile records the positions of the definitions of file, from its source
+// code info.
+func (d *Diagnostics) addFile(file *descriptor.FileDescriptorProto) {
+	spans := map[string][]int32{}
+	for _, loc := range file.GetSourceCodeInfo().GetLocation() {
+		key := pathKey(loc.Path)
+		if _, ok := spans[key]; !ok {
+			spans[key] = loc.Span
+		}
+	}
+	add := func(def interface{}, path []int32) {
+		pos := position{file: file.GetName()}
+		if span := spans[pathKey(path)]; len(span) >= 2 {
+			pos.line = int(span[0]) + 1
+			pos.column = int(span[1]) + 1
+		}
+		d.positions[def] = pos
+	}
+
+	addEnum := func(enum *descriptor.EnumDescriptorProto, path []int32) {
+		add(enum, path)
+		for i, value := range enum.Value {
+			add(value, appendPath(path, enumValuePath, i))
+		}
+	}
+	var addMessage func(msg *descriptor.DescriptorProto, path []int32)
+	addMessage = func(msg *descriptor.DescriptorProto, path []int32) {
+		add(msg, path)
+		for i, field := range msg.Field {
+			add(field, appendPath(path, messageFieldPath, i))
+		}
+		for i, field := range msg.Extension {
+			add(field, appendPath(path, messageExtensionPath, i))
+		}
+		for i, nested := range msg.NestedType {
+			addMessage(nested, appendPath(path, messageNestedPath, i))
+		}
+		for i, enum := range msg.EnumType {
+			addEnum(enum, appendPath(path, messageEnumPath, i))
+		}
+	}
+
+	add(file, nil)
+	for i, msg := range file.MessageType {
+		addMessage(msg, []int32{fileMessagePath, int32(i)})
+	}
+	for i, enum := range file.EnumType {
+		addEnum(enum, []int32{fileEnumPath, int32(i)})
+	}
+	for i, field := range file.Extension {
+		add(field, []int32{fileExtensionPath, int32(i)})
+	}
+	for i, service := range file.Service {
+		path := []int32{fileServicePath, int32(i)}
+		add(service, path)
+		for j, method := range service.Method {
+			add(method, appendPath(path, serviceMethodPath, j))
+		}
+	}
+}
+
+// appendPath returns path followed by the element i of the field number
+// field, without modifying path.
+func appendPath(path []int32, field int32, i int) []int32 {
+	return append(append([]int32{}, path...), field, int32(i))
+}
+
+func pathKey(path []int32) string {
+	return fmt.Sprint(path)
+}
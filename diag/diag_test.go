@@ -0,0 +1,154 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package diag
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+const testProto = `syntax = "proto2";
+package test;
+
+message Outer {
+  optional string name = 1;
+  message Inner {
+    enum Kind {
+      KIND_A = 1;
+    }
+  }
+  extend Outer {
+    optional int32 nested_ext = 101;
+  }
+  extensions 100 to 200;
+}
+
+enum Color {
+  RED = 1;
+    GREEN = 2;
+}
+
+extend Outer {
+  optional int32 file_ext = 100;
+}
+
+service Things {
+  rpc Get(Outer) returns (Outer);
+}
+`
+
+// TestPositions checks that every kind of definition is located at the
+// line and column its declaration starts at.
+func TestPositions(t *testing.T) {
+	file := parseFile(t, testProto)
+	outer := file.MessageType[0]
+	inner := outer.NestedType[0]
+	for _, c := range []struct {
+		def  interface{}
+		want string
+	}{
+		{file, "test.proto:1:1: warning: x"},
+		{outer, "test.proto:4:1: warning: x"},
+		{outer.Field[0], "test.proto:5:3: warning: x"},
+		{inner, "test.proto:6:3: warning: x"},
+		{inner.EnumType[0], "test.proto:7:5: warning: x"},
+		{inner.EnumType[0].Value[0], "test.proto:8:7: warning: x"},
+		{outer.Extension[0], "test.proto:12:5: warning: x"},
+		{file.EnumType[0], "test.proto:17:1: warning: x"},
+		{file.EnumType[0].Value[1], "test.proto:19:5: warning: x"},
+		{file.Extension[0], "test.proto:23:3: warning: x"},
+		{file.Service[0], "test.proto:26:1: warning: x"},
+		{file.Service[0].Method[0], "test.proto:27:3: warning: x"},
+	} {
+		d := New([]*descriptor.FileDescriptorProto{file})
+		d.Warnf(c.def, "x")
+		if got := d.List()[0].String(); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestWithoutSourceCodeInfo(t *testing.T) {
+	file := parseFile(t, testProto)
+	file.SourceCodeInfo = nil
+	d := New([]*descriptor.FileDescriptorProto{file})
+	d.Errorf(file.MessageType[0], "no %s", "location")
+	d.Errorf(&descriptor.DescriptorProto{Name: proto.String("Other")}, "unknown definition")
+	d.Warnf(nil, "no definition")
+	want := []string{
+		"test.proto: error: no location",
+		"error: unknown definition",
+		"warning: no definition",
+	}
+	got := d.List()
+	if len(got) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("got %q, want %q", got[i].String(), want[i])
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	file := parseFile(t, testProto)
+	d := New([]*descriptor.FileDescriptorProto{file})
+	if got := d.Summary(); got != "" {
+		t.Errorf("got summary %q without diagnostics", got)
+	}
+	d.Warnf(file.Service[0], "first")
+	d.Errorf(file.MessageType[0], "second")
+	// Diagnostics reported twice are only listed once.
+	d.Warnf(file.Service[0], "first")
+	want := "test.proto:26:1: warning: first\ntest.proto:4:1: error: second\n1 error, 1 warning"
+	if got := d.Summary(); got != want {
+		t.Errorf("got summary\n%s\nwant\n%s", got, want)
+	}
+	if errors, warnings := d.Count(); errors != 1 || warnings != 1 {
+		t.Errorf("got %d errors and %d warnings, want 1 of each", errors, warnings)
+	}
+}
+
+func TestNilDiagnostics(t *testing.T) {
+	var d *Diagnostics
+	d.Warnf(nil, "discarded")
+	d.Errorf(nil, "discarded")
+	if len(d.List()) != 0 || d.Summary() != "" {
+		t.Error("nil diagnostics kept what was reported")
+	}
+}
+
+func parseFile(t *testing.T, src string) *descriptor.FileDescriptorProto {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "diag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.proto"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files[len(files)-1]
+}
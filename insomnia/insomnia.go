@@ -0,0 +1,150 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package insomnia models the resources of Insomnia exports, which can be
+// imported into Insomnia.
+package insomnia
+
+import "encoding/json"
+
+// Export describes the structure of an Insomnia export
+type Export struct {
+	ExportType   string        `json:"_type"`
+	ExportFormat int           `json:"__export_format"`
+	ExportSource string        `json:"__export_source"`
+	Resources    []interface{} `json:"resources"`
+}
+
+// Resource describes the structure of an Insomnia resource
+type Resource struct {
+	Type     string  `json:"_type"`
+	ID       string  `json:"_id"`
+	ParentID *string `json:"parentId"`
+	Name     string  `json:"name"`
+}
+
+// Workspace describes the structure of an Insomnia Workspace
+type Workspace struct {
+	Resource
+}
+
+// Environment describes the structure of an Insomnia Environment
+type Environment struct {
+	Resource
+	Data map[string]string `json:"data"`
+}
+
+// RequestGroup describes the structure of an Insomnia RequestGroup
+type RequestGroup struct {
+	Resource
+	Description string            `json:"description"`
+	Environment map[string]string `json:"environment"`
+}
+
+// Request describes the structure of an Insomnia Request
+type Request struct {
+	Resource
+	Description string              `json:"description"`
+	Method      string              `json:"method"`
+	URL         string              `json:"url"`
+	Parameters  []map[string]string `json:"parameters,omitempty"`
+	Headers     []map[string]string `json:"headers"`
+	Body        RequestBody         `json:"body"`
+	// Authentication is omitted for requests without authentication.
+	Authentication map[string]string `json:"authentication,omitempty"`
+}
+
+// RequestBody describes the structure of an Insomnia RequestBody
+type RequestBody struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	FileName string `json:"fileName,omitempty"`
+}
+
+// GrpcRequest describes the structure of an Insomnia gRPC request
+type GrpcRequest struct {
+	Resource
+	Description     string              `json:"description"`
+	URL             string              `json:"url"`
+	ProtoFileID     string              `json:"protoFileId"`
+	ProtoMethodName string              `json:"protoMethodName"`
+	Body            GrpcRequestBody     `json:"body"`
+	Metadata        []map[string]string `json:"metadata"`
+}
+
+// GrpcRequestBody describes the structure of an Insomnia gRPC request body
+type GrpcRequestBody struct {
+	Text string `json:"text"`
+}
+
+// ProtoFile describes the structure of an Insomnia ProtoFile
+type ProtoFile struct {
+	Resource
+	ProtoText string `json:"protoText"`
+}
+
+// ProtoDirectory describes the structure of an Insomnia ProtoDirectory
+type ProtoDirectory struct {
+	Resource
+}
+
+// UnitTestSuite describes the structure of an Insomnia UnitTestSuite
+type UnitTestSuite struct {
+	Resource
+}
+
+// UnitTest describes the structure of an Insomnia UnitTest
+type UnitTest struct {
+	Resource
+	Code      string `json:"code"`
+	RequestID string `json:"requestId"`
+}
+
+// laterResource is implemented by the resources introduced in later versions
+// of the export format, which need that version to be imported.
+type laterResource interface {
+	exportFormat() int
+}
+
+func (GrpcRequest) exportFormat() int    { return 4 }
+func (ProtoFile) exportFormat() int      { return 4 }
+func (ProtoDirectory) exportFormat() int { return 4 }
+func (UnitTestSuite) exportFormat() int  { return 4 }
+func (UnitTest) exportFormat() int       { return 4 }
+
+// NewExport returns an export from source without resources, in version 3 of
+// the export format.
+func NewExport(source string) *Export {
+	return &Export{
+		ExportType:   "export",
+		ExportFormat: 3,
+		ExportSource: source,
+		Resources:    []interface{}{},
+	}
+}
+
+// Add appends resources to the export, raising its format to the version
+// the resources were introduced in.
+func (e *Export) Add(resources ...interface{}) {
+	for _, r := range resources {
+		if r, ok := r.(laterResource); ok && r.exportFormat() > e.ExportFormat {
+			e.ExportFormat = r.exportFormat()
+		}
+	}
+	e.Resources = append(e.Resources, resources...)
+}
+
+// Marshal returns the export as indented JSON.
+func (e *Export) Marshal() ([]byte, error) {
+	return json.MarshalIndent(e, "", "\t")
+}
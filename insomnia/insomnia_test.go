@@ -0,0 +1,62 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package insomnia
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExportFormat(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		resources []interface{}
+		want      int
+	}{
+		{"empty", nil, 3},
+		{"requests", []interface{}{Workspace{}, RequestGroup{}, Request{}, Environment{}}, 3},
+		{"gRPC request", []interface{}{Request{}, GrpcRequest{}}, 4},
+		{"proto file", []interface{}{ProtoFile{}}, 4},
+		{"unit tests", []interface{}{UnitTestSuite{}, UnitTest{}}, 4},
+	} {
+		export := NewExport("test")
+		export.Add(c.resources...)
+		if export.ExportFormat != c.want {
+			t.Errorf("%s: got format %d, want %d", c.name, export.ExportFormat, c.want)
+		}
+	}
+
+	export := NewExport("test")
+	export.Add(Workspace{Resource: Resource{Type: "workspace", ID: "workspace", Name: "Test"}})
+	b, err := export.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"_type":           "export",
+		"__export_format": 3.0,
+		"__export_source": "test",
+		"resources": []interface{}{
+			map[string]interface{}{"_type": "workspace", "_id": "workspace", "parentId": nil, "name": "Test"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got export %s, want %v", b, want)
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// rawField is a field of a message in the protobuf wire format, decoded no
+// further than its wire type needs.
+type rawField struct {
+	wireType int
+	value    uint64 // Value of varint and fixed fields.
+	bytes    []byte // Value of length-delimited fields and groups.
+}
+
+// rawFields are the fields of a message by number, in the order they're
+// encoded.
+type rawFields map[int32][]rawField
+
+// parseFields splits b, a message in the protobuf wire format, into its
+// fields.
+func parseFields(b []byte) (rawFields, error) {
+	fields := rawFields{}
+	for len(b) > 0 {
+		key, n := proto.DecodeVarint(b)
+		if n == 0 {
+			return nil, errTruncated
+		}
+		number := int32(key >> 3)
+		var f rawField
+		var err error
+		f, b, err = nextValue(b[n:], number, int(key&7))
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %v", number, err)
+		}
+		fields[number] = append(fields[number], f)
+	}
+	return fields, nil
+}
+
+var errTruncated = errors.New("message is truncated")
+
+// nextValue decodes the value of the given wire type of field number at the
+// start of b, returning it and the rest of b.
+func nextValue(b []byte, number int32, wireType int) (rawField, []byte, error) {
+	f := rawField{wireType: wireType}
+	switch wireType {
+	case proto.WireVarint:
+		v, n := proto.DecodeVarint(b)
+		if n == 0 {
+			return f, nil, errTruncated
+		}
+		f.value = v
+		return f, b[n:], nil
+	case proto.WireFixed64:
+		if len(b) < 8 {
+			return f, nil, errTruncated
+		}
+		f.value = binary.LittleEndian.Uint64(b)
+		return f, b[8:], nil
+	case proto.WireFixed32:
+		if len(b) < 4 {
+			return f, nil, errTruncated
+		}
+		f.value = uint64(binary.LittleEndian.Uint32(b))
+		return f, b[4:], nil
+	case proto.WireBytes:
+		l, n := proto.DecodeVarint(b)
+		if n == 0 || uint64(len(b)-n) < l {
+			return f, nil, errTruncated
+		}
+		f.bytes = b[n : n+int(l)]
+		return f, b[n+int(l):], nil
+	case proto.WireStartGroup:
+		// A group holds the fields up to the end group tag of its number.
+		for rest := b; ; {
+			key, n := proto.DecodeVarint(rest)
+			if n == 0 {
+				return f, nil, errTruncated
+			}
+			if int(key&7) == proto.WireEndGroup {
+				if int32(key>>3) != number {
+					return f, nil, fmt.Errorf("group ends with the tag of field %d", key>>3)
+				}
+				f.bytes = b[:len(b)-len(rest)]
+				return f, rest[n:], nil
+			}
+			var err error
+			if _, rest, err = nextValue(rest[n:], int32(key>>3), int(key&7)); err != nil {
+				return f, nil, err
+			}
+		}
+	}
+	return f, nil, fmt.Errorf("unsupported wire type %d", wireType)
+}
+
+// varint returns the last value of the varint field number, or 0 if it's not
+// set.
+func (fields rawFields) varint(number int32) uint64 {
+	f := fields[number]
+	if len(f) == 0 {
+		return 0
+	}
+	return f[len(f)-1].value
+}
+
+// last returns the last value of the scalar field, or its zero value if it's
+// not set.
+func (fields rawFields) last(field *descriptor.FieldDescriptorProto) rawField {
+	f := fields[field.GetNumber()]
+	if len(f) == 0 {
+		return rawField{wireType: wireType(field)}
+	}
+	return f[len(f)-1]
+}
+
+// decodeMessage returns the message of type messageDefinition encoded in b
+// as JSON, as m writes it. Only the fields set in b are written, in the order
+// they're declared, followed by the extensions set.
+func (g *Generator) decodeMessage(messageDefinition *typemap.MessageDefinition, b []byte, m *jsonpb.Marshaler) (string, error) {
+	if s, ok, err := g.decodeWellKnown(messageDefinition.ProtoName(), b, m); ok || err != nil {
+		return s, err
+	}
+	fields, err := parseFields(b)
+	if err != nil {
+		return "", err
+	}
+	entries := []string{}
+	add := func(key string, field *descriptor.FieldDescriptorProto) error {
+		values := fields[field.GetNumber()]
+		if len(values) == 0 {
+			if m.EmitDefaults && (field.OneofIndex == nil || presence.Proto3Optional(field)) {
+				value, err := g.unsetValue(field, m)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, jsonString(key)+":"+value)
+			}
+			return nil
+		}
+		value, err := g.decodeField(field, values, m)
+		if err != nil {
+			return err
+		}
+		if !m.EmitDefaults && g.presence.Of(field) == presence.Implicit && g.isDefault(field, value) {
+			return nil
+		}
+		entries = append(entries, jsonString(key)+":"+value)
+		return nil
+	}
+	for _, field := range messageDefinition.Descriptor.Field {
+		key := field.GetJsonName()
+		if m.OrigName {
+			key = field.GetName()
+		}
+		if err := add(key, field); err != nil {
+			return "", err
+		}
+	}
+	for _, ext := range g.Extensions(messageDefinition.ProtoName()) {
+		if err := add("["+ext.Name+"]", ext.Field); err != nil {
+			return "", err
+		}
+	}
+	return "{" + strings.Join(entries, ",") + "}", nil
+}
+
+// decodeField returns the values of field as JSON: a map, a list, or the
+// last value of a singular field, with those of message fields merged.
+func (g *Generator) decodeField(field *descriptor.FieldDescriptorProto, values []rawField, m *jsonpb.Marshaler) (string, error) {
+	if entry := g.mapEntry(field); entry != nil {
+		keyField, valueField := entry.Descriptor.Field[0], entry.Descriptor.Field[1]
+		pairs := map[string]string{}
+		for _, v := range values {
+			fields, err := parseFields(v.bytes)
+			if err != nil {
+				return "", err
+			}
+			key, err := g.decodeScalar(keyField, fields.last(keyField), m)
+			if err != nil {
+				return "", err
+			}
+			if !strings.HasPrefix(key, "\"") {
+				key = jsonString(key)
+			}
+			if pairs[key], err = g.decodeScalar(valueField, fields.last(valueField), m); err != nil {
+				return "", err
+			}
+		}
+		keys := make([]string, 0, len(pairs))
+		for k := range pairs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = k + ":" + pairs[k]
+		}
+		return "{" + strings.Join(entries, ",") + "}", nil
+	}
+
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		elems := []string{}
+		for _, v := range values {
+			unpacked, err := unpack(field, v)
+			if err != nil {
+				return "", err
+			}
+			for _, u := range unpacked {
+				elem, err := g.decodeScalar(field, u, m)
+				if err != nil {
+					return "", err
+				}
+				elems = append(elems, elem)
+			}
+		}
+		return "[" + strings.Join(elems, ",") + "]", nil
+	}
+
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE || field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		merged := rawField{wireType: values[0].wireType}
+		for _, v := range values {
+			merged.bytes = append(merged.bytes, v.bytes...)
+		}
+		return g.decodeScalar(field, merged, m)
+	}
+	return g.decodeScalar(field, values[len(values)-1], m)
+}
+
+// unsetValue returns the JSON jsonpb writes for field when it's not set
+// and EmitDefaults is: the zero value of its type if it has implicit
+// presence, or else null.
+func (g *Generator) unsetValue(field *descriptor.FieldDescriptorProto, m *jsonpb.Marshaler) (string, error) {
+	switch {
+	case g.mapEntry(field) != nil:
+		return "{}", nil
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return "[]", nil
+	case g.presence.Of(field) != presence.Implicit:
+		return "null", nil
+	}
+	return g.decodeScalar(field, rawField{wireType: wireType(field)}, m)
+}
+
+// unpack returns the values of a packed repeated field, or v itself if it
+// isn't packed.
+func unpack(field *descriptor.FieldDescriptorProto, v rawField) ([]rawField, error) {
+	elemWireType := wireType(field)
+	if v.wireType != proto.WireBytes || elemWireType == proto.WireBytes {
+		return []rawField{v}, nil
+	}
+	values := []rawField{}
+	for b := v.bytes; len(b) > 0; {
+		var u rawField
+		var err error
+		u, b, err = nextValue(b, field.GetNumber(), elemWireType)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		values = append(values, u)
+	}
+	return values, nil
+}
+
+// wireType returns the wire type of the values of field, save for editions
+// message fields that are delimited, which are groups.
+func wireType(field *descriptor.FieldDescriptorProto) int {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return proto.WireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return proto.WireFixed32
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return proto.WireBytes
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return proto.WireStartGroup
+	}
+	return proto.WireVarint
+}
+
+// decodeScalar returns a single value of field as JSON, as m writes it.
+func (g *Generator) decodeScalar(field *descriptor.FieldDescriptorProto, v rawField, m *jsonpb.Marshaler) (string, error) {
+	want := wireType(field)
+	if g.presence.Delimited(field) {
+		want = proto.WireStartGroup
+	}
+	if v.wireType != want {
+		return "", fmt.Errorf("field %s: unexpected wire type %d", field.GetName(), v.wireType)
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return jsonFloatString(math.Float64frombits(v.value), 64), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return jsonFloatString(float64(math.Float32frombits(uint32(v.value))), 32), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32:
+		return strconv.FormatInt(int64(int32(v.value)), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return strconv.FormatInt(int64(int32(uint32(v.value)>>1)^-int32(v.value&1)), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return strconv.FormatInt(int64(int32(uint32(v.value))), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return strconv.FormatUint(uint64(uint32(v.value)), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return `"` + strconv.FormatInt(int64(v.value), 10) + `"`, nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return `"` + strconv.FormatInt(int64(v.value>>1)^-int64(v.value&1), 10) + `"`, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return `"` + strconv.FormatUint(v.value, 10) + `"`, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return strconv.FormatBool(v.value != 0), nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return jsonString(string(v.bytes)), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return `"` + base64.StdEncoding.EncodeToString(v.bytes) + `"`, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		n := int32(v.value)
+		if !m.EnumsAsInts {
+			if enum := g.Enum(field.GetTypeName()); enum != nil {
+				for _, value := range enum.GetValue() {
+					if value.GetNumber() == n {
+						return jsonString(value.GetName()), nil
+					}
+				}
+			}
+		}
+		return strconv.Itoa(int(n)), nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if s, ok, err := g.decodeWellKnown(field.GetTypeName(), v.bytes, m); ok || err != nil {
+			return s, err
+		}
+		msg := g.registry.MessageDefinition(field.GetTypeName())
+		if msg == nil {
+			return "", fmt.Errorf("field %s: message %s could not be found", field.GetName(), field.GetTypeName())
+		}
+		return g.decodeMessage(msg, v.bytes, m)
+	}
+	return "", fmt.Errorf("field %s: unsupported type %s", field.GetName(), field.GetType())
+}
+
+// jsonFloatString returns f as JSON, with the given precision in bits, and
+// the values JSON numbers can't hold as the strings jsonpb writes for them.
+func jsonFloatString(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return `"NaN"`
+	case math.IsInf(f, 1):
+		return `"Infinity"`
+	case math.IsInf(f, -1):
+		return `"-Infinity"`
+	}
+	var b []byte
+	if bits == 32 {
+		b, _ = json.Marshal(float32(f))
+	} else {
+		b, _ = json.Marshal(f)
+	}
+	return string(b)
+}
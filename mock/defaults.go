@@ -0,0 +1,150 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// defaultValue returns the default value declared for field as JSON. protoc
+// stores default values as text: numbers and bools as they're written, enum
+// values by name, strings as is and bytes C-escaped.
+func (g *Generator) defaultValue(field *descriptor.FieldDescriptorProto) (string, error) {
+	text := field.GetDefaultValue()
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		switch text {
+		case "inf":
+			return `"Infinity"`, nil
+		case "-inf":
+			return `"-Infinity"`, nil
+		case "nan":
+			return `"NaN"`, nil
+		}
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return "", fmt.Errorf("invalid default value %q", text)
+		}
+		return text, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if text != "true" && text != "false" {
+			return "", fmt.Errorf("invalid default value %q", text)
+		}
+		return text, nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return jsonString(text), nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		b, err := cUnescape(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid default value %q: %v", text, err)
+		}
+		return jsonString(base64.StdEncoding.EncodeToString(b)), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum := g.Enum(field.GetTypeName()); enum != nil {
+			for _, value := range enum.GetValue() {
+				if value.GetName() == text {
+					return jsonString(text), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("enum %s has no value %s", field.GetTypeName(), text)
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "", fmt.Errorf("messages can't have default values")
+	}
+	// All remaining types are integers.
+	if _, err := strconv.ParseInt(text, 10, 64); err != nil {
+		if _, err := strconv.ParseUint(text, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid default value %q", text)
+		}
+	}
+	return text, nil
+}
+
+// jsonString returns s as a JSON string. HTML characters aren't escaped,
+// since mocks are only read as JSON.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return string(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
+
+// cUnescape reverses the C escaping protoc applies to the default values of
+// bytes fields.
+func cUnescape(s string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, fmt.Errorf("trailing backslash")
+		}
+		switch c := s[i]; c {
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '\\', '\'', '"', '?':
+			b = append(b, c)
+		case 'x', 'X':
+			j := i + 1
+			for j < len(s) && j < i+3 && isHexDigit(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid escape \\%c", c)
+			}
+			n, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+			b = append(b, byte(n))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape \\%s", s[i:j])
+			}
+			b = append(b, byte(n))
+			i = j - 1
+		default:
+			return nil, fmt.Errorf("invalid escape \\%c", c)
+		}
+	}
+	return b, nil
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
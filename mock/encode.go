@@ -0,0 +1,369 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// Encode converts a JSON body, such as a mock generated by Message, into the
+// protobuf wire format of messageDefinition.
+func (g *Generator) Encode(messageDefinition *typemap.MessageDefinition, body string) ([]byte, error) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var value map[string]interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	buf := proto.NewBuffer(nil)
+	if err := g.encodeMessage(buf, messageDefinition, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *Generator) encodeMessage(buf *proto.Buffer, messageDefinition *typemap.MessageDefinition, value map[string]interface{}) error {
+	for _, field := range messageDefinition.Descriptor.Field {
+		v, ok := value[field.GetJsonName()]
+		if !ok {
+			v, ok = value[field.GetName()]
+		}
+		if err := g.encodeValue(buf, field, v, ok); err != nil {
+			return err
+		}
+	}
+	// Extensions are keyed by their names in brackets.
+	for _, ext := range g.Extensions(messageDefinition.ProtoName()) {
+		v, ok := value["["+ext.Name+"]"]
+		if err := g.encodeValue(buf, ext.Field, v, ok); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeValue writes v, the value of field, unless it's missing or null.
+// Only google.protobuf.Value can hold null.
+func (g *Generator) encodeValue(buf *proto.Buffer, field *descriptor.FieldDescriptorProto, v interface{}, ok bool) error {
+	if !ok || v == nil && field.GetTypeName() != valueType {
+		return nil
+	}
+	if entry := g.mapEntry(field); entry != nil {
+		if obj, ok := v.(map[string]interface{}); ok {
+			return g.encodeMap(buf, field, entry, obj)
+		}
+	}
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return g.encodeField(buf, field, v)
+	}
+	elems, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("field %s: expected a list, got %T", field.GetName(), v)
+	}
+	for _, elem := range elems {
+		if err := g.encodeField(buf, field, elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) encodeField(buf *proto.Buffer, field *descriptor.FieldDescriptorProto, v interface{}) error {
+	tag := uint64(field.GetNumber()) << 3
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		f, err := jsonFloat(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireFixed64)
+		return buf.EncodeFixed64(math.Float64bits(f))
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := jsonFloat(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireFixed32)
+		return buf.EncodeFixed32(uint64(math.Float32bits(float32(f))))
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_INT64:
+		i, err := jsonInt(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireVarint)
+		return buf.EncodeVarint(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64:
+		u, err := jsonUint(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireVarint)
+		return buf.EncodeVarint(u)
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		i, err := jsonInt(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireVarint)
+		return buf.EncodeZigzag32(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		i, err := jsonInt(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireVarint)
+		return buf.EncodeZigzag64(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		u, err := jsonUint(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireFixed32)
+		return buf.EncodeFixed32(uint64(uint32(u)))
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		i, err := jsonInt(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireFixed32)
+		return buf.EncodeFixed32(uint64(uint32(i)))
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		u, err := jsonUint(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireFixed64)
+		return buf.EncodeFixed64(u)
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		i, err := jsonInt(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireFixed64)
+		return buf.EncodeFixed64(uint64(i))
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("field %s: expected a bool, got %T", field.GetName(), v)
+		}
+		buf.EncodeVarint(tag | proto.WireVarint)
+		if b {
+			return buf.EncodeVarint(1)
+		}
+		return buf.EncodeVarint(0)
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("field %s: expected a string, got %T", field.GetName(), v)
+		}
+		buf.EncodeVarint(tag | proto.WireBytes)
+		return buf.EncodeStringBytes(s)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("field %s: expected a base64 string, got %T", field.GetName(), v)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("field %s: invalid base64 string: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireBytes)
+		return buf.EncodeRawBytes(b)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		n, err := g.enumNumber(field.GetTypeName(), v)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", field.GetName(), err)
+		}
+		buf.EncodeVarint(tag | proto.WireVarint)
+		return buf.EncodeVarint(uint64(n))
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		b, err := g.encodeNestedMessage(field, v)
+		if err != nil {
+			return err
+		}
+		// Delimited messages, like proto2 groups, are written between start
+		// and end group tags instead of after their length.
+		if g.presence.Delimited(field) {
+			buf.EncodeVarint(tag | proto.WireStartGroup)
+			buf.SetBuf(append(buf.Bytes(), b...))
+			return buf.EncodeVarint(tag | proto.WireEndGroup)
+		}
+		buf.EncodeVarint(tag | proto.WireBytes)
+		return buf.EncodeRawBytes(b)
+	}
+	return fmt.Errorf("field %s: unsupported type %s", field.GetName(), field.GetType())
+}
+
+// encodeMap writes the entries of obj, the JSON object of the map field,
+// in the order of their keys, so that encodings are stable.
+func (g *Generator) encodeMap(buf *proto.Buffer, field *descriptor.FieldDescriptorProto, entry *typemap.MessageDefinition, obj map[string]interface{}) error {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	keyField := entry.Descriptor.Field[0]
+	for _, k := range keys {
+		// Keys are strings in JSON, whatever their type.
+		var key interface{} = k
+		if keyField.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL {
+			b, err := strconv.ParseBool(k)
+			if err != nil {
+				return fmt.Errorf("field %s: invalid key %q", field.GetName(), k)
+			}
+			key = b
+		}
+		value := map[string]interface{}{keyField.GetJsonName(): key, entry.Descriptor.Field[1].GetJsonName(): obj[k]}
+		if err := g.encodeField(buf, field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) encodeNestedMessage(field *descriptor.FieldDescriptorProto, v interface{}) ([]byte, error) {
+	inner := proto.NewBuffer(nil)
+	if ok, err := g.encodeWellKnown(inner, field.GetName(), field.GetTypeName(), v); ok || err != nil {
+		return inner.Bytes(), err
+	}
+
+	msg := g.registry.MessageDefinition(field.GetTypeName())
+	if msg == nil {
+		return nil, fmt.Errorf("field %s: message %s could not be found", field.GetName(), field.GetTypeName())
+	}
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field %s: expected an object, got %T", field.GetName(), v)
+	}
+	if err := g.encodeMessage(inner, msg, value); err != nil {
+		return nil, err
+	}
+	return inner.Bytes(), nil
+}
+
+// encodeSecondsNanos writes the fields shared by google.protobuf.Timestamp and
+// google.protobuf.Duration, omitting them when zero as proto3 does.
+func encodeSecondsNanos(buf *proto.Buffer, seconds, nanos int64) {
+	if seconds != 0 {
+		buf.EncodeVarint(1<<3 | proto.WireVarint)
+		buf.EncodeVarint(uint64(seconds))
+	}
+	if nanos != 0 {
+		buf.EncodeVarint(2<<3 | proto.WireVarint)
+		buf.EncodeVarint(uint64(nanos))
+	}
+}
+
+// enumNumber returns the number of the value v, given either by name or by
+// number, of the enum typeName.
+func (g *Generator) enumNumber(typeName string, v interface{}) (int32, error) {
+	if n, ok := v.(json.Number); ok {
+		i, err := n.Int64()
+		return int32(i), err
+	}
+	name, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("expected an enum value, got %T", v)
+	}
+	enum := g.Enum(typeName)
+	if enum == nil {
+		return 0, fmt.Errorf("enum %s could not be found", typeName)
+	}
+	for _, value := range enum.GetValue() {
+		if value.GetName() == name {
+			return value.GetNumber(), nil
+		}
+	}
+	return 0, fmt.Errorf("enum %s has no value %s", typeName, name)
+}
+
+// Enum returns the enum with the fully-qualified name typeName from any of
+// the files, or nil if there is none.
+func (g *Generator) Enum(typeName string) *descriptor.EnumDescriptorProto {
+	for _, file := range g.files {
+		prefix := "."
+		if pkg := file.GetPackage(); pkg != "" {
+			prefix += pkg + "."
+		}
+		for _, enum := range file.EnumType {
+			if prefix+enum.GetName() == typeName {
+				return enum
+			}
+		}
+		if enum := findNestedEnum(file.MessageType, prefix, typeName); enum != nil {
+			return enum
+		}
+	}
+	return nil
+}
+
+func findNestedEnum(messages []*descriptor.DescriptorProto, prefix, typeName string) *descriptor.EnumDescriptorProto {
+	for _, msg := range messages {
+		msgPrefix := prefix + msg.GetName() + "."
+		if !strings.HasPrefix(typeName, msgPrefix) {
+			continue
+		}
+		for _, enum := range msg.EnumType {
+			if msgPrefix+enum.GetName() == typeName {
+				return enum
+			}
+		}
+		if enum := findNestedEnum(msg.NestedType, msgPrefix, typeName); enum != nil {
+			return enum
+		}
+	}
+	return nil
+}
+
+func jsonFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
+
+func jsonInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return strconv.ParseInt(n.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	}
+	return 0, fmt.Errorf("expected an integer, got %T", v)
+}
+
+func jsonUint(v interface{}) (uint64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return strconv.ParseUint(n.String(), 10, 64)
+	case string:
+		return strconv.ParseUint(n, 10, 64)
+	}
+	return 0, fmt.Errorf("expected an unsigned integer, got %T", v)
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/thesilentg/proto-to-insomnia/diag"
+)
+
+func TestEncodeUnsigned(t *testing.T) {
+	g, registry := newTestGenerator(t, "encode.proto")
+	msg := testMessage(t, registry, ".mocktest.encode.Numbers")
+	b, err := g.Encode(msg, `{"unsigned": "18446744073709551615", "fixed": "18446744073709551615", "small": 4294967295, "smallFixed": 4294967295, "signed": "-9223372036854775808"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := proto.NewBuffer(nil)
+	want.EncodeVarint(1<<3 | proto.WireVarint)
+	want.EncodeVarint(1<<64 - 1)
+	want.EncodeVarint(2<<3 | proto.WireFixed64)
+	want.EncodeFixed64(1<<64 - 1)
+	want.EncodeVarint(3<<3 | proto.WireVarint)
+	want.EncodeVarint(1<<32 - 1)
+	want.EncodeVarint(4<<3 | proto.WireFixed32)
+	want.EncodeFixed32(1<<32 - 1)
+	want.EncodeVarint(5<<3 | proto.WireVarint)
+	want.EncodeVarint(1 << 63)
+	if !bytes.Equal(b, want.Bytes()) {
+		t.Errorf("got %x, want %x", b, want.Bytes())
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	g, registry := newTestGenerator(t, "encode.proto")
+	msg := testMessage(t, registry, ".mocktest.encode.Numbers")
+	for _, c := range []struct {
+		body string
+		want string
+	}{
+		{`{"unsigned": "-1"}`, "field unsigned:"},
+		{`{"fixed": "18446744073709551616"}`, "field fixed:"},
+		{`{"signed": "9223372036854775808"}`, "field signed:"},
+		{`{"data": "not base64!"}`, "field data: invalid base64 string"},
+	} {
+		_, err := g.Encode(msg, c.body)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Encode(%s): got error %v, want it to contain %q", c.body, err, c.want)
+		}
+	}
+}
+
+// TestGroups checks that proto2 groups and delimited editions message fields
+// are mocked like message fields and encoded between group tags.
+func TestGroups(t *testing.T) {
+	for _, c := range []struct {
+		file, typeName string
+		body           string
+		want           func(buf *proto.Buffer)
+	}{{
+		file:     "groups.proto",
+		typeName: ".mocktest.groups.Search",
+		body:     `{"query":"q","result":{"url":"u","pages":[1,2]},"snippet":[{"text":"a"},{}]}`,
+		want: func(buf *proto.Buffer) {
+			buf.EncodeVarint(1<<3 | proto.WireBytes)
+			buf.EncodeStringBytes("q")
+			buf.EncodeVarint(2<<3 | proto.WireStartGroup)
+			buf.EncodeVarint(3<<3 | proto.WireBytes)
+			buf.EncodeStringBytes("u")
+			buf.EncodeVarint(4<<3 | proto.WireVarint)
+			buf.EncodeVarint(1)
+			buf.EncodeVarint(4<<3 | proto.WireVarint)
+			buf.EncodeVarint(2)
+			buf.EncodeVarint(2<<3 | proto.WireEndGroup)
+			buf.EncodeVarint(5<<3 | proto.WireStartGroup)
+			buf.EncodeVarint(6<<3 | proto.WireBytes)
+			buf.EncodeStringBytes("a")
+			buf.EncodeVarint(5<<3 | proto.WireEndGroup)
+			buf.EncodeVarint(5<<3 | proto.WireStartGroup)
+			buf.EncodeVarint(5<<3 | proto.WireEndGroup)
+		},
+	}, {
+		file:     "delimited.proto",
+		typeName: ".mocktest.delimited.Search",
+		body:     `{"query":"q","result":{"url":"u"}}`,
+		want: func(buf *proto.Buffer) {
+			buf.EncodeVarint(1<<3 | proto.WireBytes)
+			buf.EncodeStringBytes("q")
+			buf.EncodeVarint(2<<3 | proto.WireStartGroup)
+			buf.EncodeVarint(3<<3 | proto.WireBytes)
+			buf.EncodeStringBytes("u")
+			buf.EncodeVarint(2<<3 | proto.WireEndGroup)
+		},
+	}} {
+		t.Run(c.file, func(t *testing.T) {
+			g, registry := newTestGenerator(t, c.file)
+			g.diags = diag.New(nil)
+			msg := testMessage(t, registry, c.typeName)
+			if mock := g.Message(msg); strings.Contains(mock, "PARSE_ERROR") {
+				t.Errorf("mocked with a placeholder: %s", mock)
+			}
+			if diags := g.diags.List(); len(diags) > 0 {
+				t.Errorf("got diagnostics %v", diags)
+			}
+
+			b, err := g.Encode(msg, c.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := proto.NewBuffer(nil)
+			c.want(want)
+			if !bytes.Equal(b, want.Bytes()) {
+				t.Errorf("got %x, want %x", b, want.Bytes())
+			}
+			decoded, err := g.decodeMessage(msg, b, &jsonpb.Marshaler{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded != c.body {
+				t.Errorf("decoded %s, want %s", decoded, c.body)
+			}
+		})
+	}
+}
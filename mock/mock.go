@@ -0,0 +1,531 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package mock generates mock messages, encoded as JSON, for the messages of
+// a set of proto files.
+//
+// Mock values are drawn from the math/rand source. Seed it with Seed before
+// generating the mocks of a method, so that they only change when the
+// method does.
+package mock
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/diag"
+	"github.com/thesilentg/proto-to-insomnia/options"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// Generator generates mock messages for the messages defined by a set of
+// files.
+//
+// A Generator isn't safe for concurrent use: it tracks the messages being
+// mocked as it descends into them, and draws values from the global
+// math/rand source, which Seed reseeds. Generate mocks from one goroutine at
+// a time, even with separate Generators.
+type Generator struct {
+	// IncludeExtensions includes the extensions of each message in its mocks,
+	// keyed by their fully-qualified names in brackets, as in the JSON
+	// mapping of proto2 extensions.
+	IncludeExtensions bool
+	// Presence is how mocks treat the fields which track presence.
+	Presence PresenceStrategy
+	// Strategy is how Message mocks messages. Defaults to Full.
+	Strategy Strategy
+	// RepeatedCount is the number of elements of repeated fields mocked with
+	// the Full strategy, and the most the Random strategy mocks. Defaults to
+	// DefaultRepeatedCount.
+	RepeatedCount int
+	// OrigName, EnumsAsInts and EmitDefaults shape mocks like the options of
+	// the same names of jsonpb.Marshaler, so that they match the messages
+	// clients send. OrigName keys fields by their names in the proto file
+	// rather than their JSON names, EnumsAsInts writes enum values as
+	// numbers, and EmitDefaults includes the fields holding their default
+	// value, which jsonpb otherwise leaves out. New sets EmitDefaults.
+	OrigName     bool
+	EnumsAsInts  bool
+	EmitDefaults bool
+
+	registry   *typemap.Registry
+	files      []*descriptor.FileDescriptorProto
+	diags      *diag.Diagnostics
+	extensions map[string][]Extension
+	presence   *presence.Index
+	// mocking counts the messages being mocked by type, to stop at
+	// recursive fields.
+	mocking map[string]int
+}
+
+// PresenceStrategy is how mocks treat the singular scalar and enum fields
+// with explicit presence: proto2 optional fields, proto3 optional fields and
+// fields whose field_presence feature is EXPLICIT. Message fields and the
+// fields of oneofs are always included.
+type PresenceStrategy int
+
+const (
+	// IncludePresence includes fields with explicit presence in mocks, as if
+	// they were set.
+	IncludePresence PresenceStrategy = iota
+	// OmitPresence leaves fields with explicit presence out of mocks, as if
+	// they were unset, unless they have an example.
+	OmitPresence
+)
+
+// Extension is an extension field, declared in any of the files.
+type Extension struct {
+	// Name is the fully-qualified name of the extension, without the leading
+	// dot. Its JSON key is the name in brackets.
+	Name  string
+	Field *descriptor.FieldDescriptorProto
+}
+
+// New returns a Generator for the messages defined by files, which must
+// include every file they import. Fields which can't be mocked are reported
+// to diags as warnings, unless it's nil.
+func New(files []*descriptor.FileDescriptorProto, diags *diag.Diagnostics) *Generator {
+	g := &Generator{
+		registry:   typemap.New(files),
+		files:      files,
+		diags:      diags,
+		extensions: map[string][]Extension{},
+		presence:   presence.New(files),
+		mocking:    map[string]int{},
+
+		RepeatedCount: DefaultRepeatedCount,
+		EmitDefaults:  true,
+	}
+	for _, file := range files {
+		prefix := file.GetPackage()
+		g.addExtensions(prefix, file.Extension)
+		g.addNestedExtensions(prefix, file.MessageType)
+	}
+	return g
+}
+
+func (g *Generator) addNestedExtensions(prefix string, messages []*descriptor.DescriptorProto) {
+	for _, msg := range messages {
+		name := msg.GetName()
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		g.addExtensions(name, msg.Extension)
+		g.addNestedExtensions(name, msg.NestedType)
+	}
+}
+
+// addExtensions records fields, declared in the scope prefix, as extensions
+// of the messages they extend.
+func (g *Generator) addExtensions(prefix string, fields []*descriptor.FieldDescriptorProto) {
+	for _, field := range fields {
+		name := field.GetName()
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		g.extensions[field.GetExtendee()] = append(g.extensions[field.GetExtendee()], Extension{Name: name, Field: field})
+	}
+}
+
+// Extensions returns the extensions of the message with the fully-qualified
+// name typeName, in the order they're declared.
+func (g *Generator) Extensions(typeName string) []Extension {
+	return g.extensions[typeName]
+}
+
+// Message returns a mock of msg as JSON, indented with tabs. Fields with an
+// (insomnia.field) option are mocked as it configures, and fields with a
+// default value, which proto2 fields can declare, are mocked with it.
+func (g *Generator) Message(msg *typemap.MessageDefinition) string {
+	return g.message(msg, 0, g.Strategy)
+}
+
+// MessageUsing returns a mock of msg like Message, using strategy instead of
+// the generator's.
+func (g *Generator) MessageUsing(msg *typemap.MessageDefinition, strategy Strategy) string {
+	return g.message(msg, 0, strategy)
+}
+
+// Seed seeds the random source with name, so that the mocks generated next
+// only depend on name. Seeding with the name of each method before mocking
+// its messages means adding a method doesn't change the mocks of the others.
+func Seed(name string) {
+	sum := md5.New().Sum([]byte(name))[:8]
+	rand.Seed(int64(binary.BigEndian.Uint64(sum)))
+}
+
+// KnownHint reports whether hint is one of the kinds of string the hint
+// field option supports.
+func KnownHint(hint string) bool {
+	return hints[hint] != nil
+}
+
+// maxDepth is the deepest level of indentation mocks descend to. Deeper
+// messages are mocked as empty messages.
+const maxDepth = 32
+
+// message returns a mock of messageDefinition, nested depth levels deep.
+// Messages are mocked as empty messages within messages of the same type,
+// since mocking the fields of recursive messages would never end, and past
+// maxDepth.
+func (g *Generator) message(messageDefinition *typemap.MessageDefinition, depth int, strategy Strategy) string {
+	name := messageDefinition.ProtoName()
+	if g.mocking[name] > 0 || depth > maxDepth {
+		return "{}"
+	}
+	g.mocking[name]++
+	defer func() { g.mocking[name]-- }()
+
+	indent := strings.Repeat("\t", depth+1)
+	entries := []string{}
+	for _, f := range oneofFields(g.strategyFields(g.mockFields(messageDefinition), strategy)) {
+		field := f.field
+		if example := options.Field(field).Example; example != "" {
+			entries = append(entries, indent+"\""+f.key+"\": "+compactJSON(example))
+			continue
+		}
+		var value string
+		if entry := g.mapEntry(field); entry != nil {
+			value = g.mapValue(entry, depth, strategy)
+		} else if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			value = g.listValue(messageDefinition, field, depth, strategy)
+		} else {
+			value = g.value(messageDefinition, field, depth, strategy)
+		}
+		if !g.EmitDefaults && g.presence.Of(field) == presence.Implicit && g.isDefault(field, value) {
+			continue
+		}
+		entries = append(entries, indent+"\""+f.key+"\": "+value)
+	}
+	if len(entries) == 0 {
+		return "{\n" + strings.Repeat("\t", depth) + "}"
+	}
+	return "{\n" + strings.Join(entries, ",\n") + "\n" + strings.Repeat("\t", depth) + "}"
+}
+
+// listValue returns a mock of the repeated field, with as many elements as
+// strategy mocks.
+func (g *Generator) listValue(messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto, depth int, strategy Strategy) string {
+	count := g.repeatedCount(strategy)
+	if count == 0 {
+		return "[]"
+	}
+	elems := make([]string, count)
+	for i := range elems {
+		elems[i] = strings.Repeat("\t", depth+2) + g.value(messageDefinition, field, depth+1, strategy)
+	}
+	return "[\n" + strings.Join(elems, ",\n") + "\n" + strings.Repeat("\t", depth+1) + "]"
+}
+
+// mapValue returns a mock of a map field, whose entries are entry, as a JSON
+// object. Keys are always strings in JSON, so those of other types are
+// written as strings, and keys drawn twice are only included once.
+func (g *Generator) mapValue(entry *typemap.MessageDefinition, depth int, strategy Strategy) string {
+	count := g.repeatedCount(strategy)
+	keyField, valueField := entry.Descriptor.Field[0], entry.Descriptor.Field[1]
+	seen := map[string]bool{}
+	entries := []string{}
+	for i := 0; i < count; i++ {
+		key := g.value(entry, keyField, depth+1, strategy)
+		if !strings.HasPrefix(key, "\"") {
+			key = jsonString(key)
+		}
+		value := g.value(entry, valueField, depth+1, strategy)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, strings.Repeat("\t", depth+2)+key+": "+value)
+	}
+	if len(entries) == 0 {
+		return "{}"
+	}
+	return "{\n" + strings.Join(entries, ",\n") + "\n" + strings.Repeat("\t", depth+1) + "}"
+}
+
+// mapEntry returns the entry message of field if it's a map field, or nil.
+func (g *Generator) mapEntry(field *descriptor.FieldDescriptorProto) *typemap.MessageDefinition {
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return nil
+	}
+	entry := g.registry.MessageDefinition(field.GetTypeName())
+	if entry == nil || !entry.Descriptor.GetOptions().GetMapEntry() || len(entry.Descriptor.Field) != 2 {
+		return nil
+	}
+	return entry
+}
+
+// value returns a mock of a single value of field, as jsonpb writes it:
+// 64-bit integers are strings, and enum values are numbers if EnumsAsInts
+// is set.
+func (g *Generator) value(messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto, depth int, strategy Strategy) string {
+	value := g.field(messageDefinition, field, depth, strategy)
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		if !strings.HasPrefix(value, "\"") {
+			return jsonString(value)
+		}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if !g.EnumsAsInts {
+			return value
+		}
+		var name string
+		if err := json.Unmarshal([]byte(value), &name); err != nil {
+			return value
+		}
+		if n, err := g.enumNumber(field.GetTypeName(), name); err == nil {
+			return strconv.Itoa(int(n))
+		}
+	}
+	return value
+}
+
+// oneofFields returns fields without the members of each oneof but the
+// first, since messages can only hold one.
+func oneofFields(fields []mockField) []mockField {
+	chosen := []mockField{}
+	seen := map[int32]bool{}
+	for _, f := range fields {
+		if f.field.OneofIndex != nil && !presence.Proto3Optional(f.field) && f.field.Extendee == nil {
+			if seen[f.field.GetOneofIndex()] {
+				continue
+			}
+			seen[f.field.GetOneofIndex()] = true
+		}
+		chosen = append(chosen, f)
+	}
+	return chosen
+}
+
+func (g *Generator) field(messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto, depth int, strategy Strategy) string {
+	if strategy == Zero {
+		if value, ok := g.zeroValue(field); ok {
+			return value
+		}
+	}
+	// Well-known types have JSON forms of their own.
+	if value, ok := g.wellKnownValue(messageDefinition, field, depth, strategy); ok {
+		return value
+	}
+	if field.DefaultValue != nil {
+		value, err := g.defaultValue(field)
+		if err == nil {
+			return value
+		}
+		g.diags.Warnf(field, "field %s: %v, so it's mocked with a random value", fieldName(messageDefinition, field), err)
+	}
+
+	switch fieldType := *field.Type; fieldType {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		randFloat := 1000*rand.Float32() - 500
+		return fmt.Sprintf("%.4f", randFloat)
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_INT64:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_INT32:
+		randInt := rand.Intn(1000) - 500
+		return strconv.Itoa(randInt)
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_UINT32:
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_UINT64:
+		randUInt := rand.Intn(1000)
+		return strconv.Itoa(randUInt)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if rand.Float32() < 0.5 {
+			return "false"
+		}
+		return "true"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		if hint := hints[options.Field(field).Hint]; hint != nil {
+			return fmt.Sprintf("\"%s\"", hint())
+		}
+		return fmt.Sprintf("\"%s\"", randomString(10))
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return fmt.Sprintf("\"%s\"", base64.StdEncoding.EncodeToString([]byte(randomString(10))))
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// Groups are written like message fields, keyed by their JSON name.
+		msg := g.registry.MessageDefinition(field.GetTypeName())
+		if msg == nil {
+			g.diags.Warnf(field, "field %s: message %s could not be found, so it's mocked with a placeholder", fieldName(messageDefinition, field), field.GetTypeName())
+			return fmt.Sprintf("\"Message %s could not be found\"", field.GetTypeName())
+		}
+		return g.message(msg, depth+1, strategy)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return g.enumValue(messageDefinition, field)
+	}
+	g.diags.Warnf(field, "field %s: type %s can't be mocked, so it's mocked with a placeholder", fieldName(messageDefinition, field), field.GetType())
+	return "\"PARSE_ERROR\""
+}
+
+func (g *Generator) enumValue(messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto) string {
+	// Check enums defined in the message
+	for _, enumType := range messageDefinition.Descriptor.EnumType {
+		if checkEnumMessageMatch(enumType, messageDefinition, field) {
+			return fmt.Sprintf("\"%s\"", randomEnumValue(enumType))
+		}
+	}
+	// Check enums defined in the file
+	for _, enumType := range messageDefinition.File.EnumType {
+		if checkEnumFileMatch(enumType, messageDefinition.File, field) {
+			return fmt.Sprintf("\"%s\"", randomEnumValue(enumType))
+		}
+	}
+	// Check enums defined in other messages and files
+	if enumType := g.Enum(field.GetTypeName()); enumType != nil && len(enumType.Value) > 0 {
+		return fmt.Sprintf("\"%s\"", randomEnumValue(enumType))
+	}
+	g.diags.Warnf(field, "field %s: enum %s could not be found, so it's mocked with a placeholder", fieldName(messageDefinition, field), field.GetTypeName())
+	return fmt.Sprintf("\"%s\"", field.GetTypeName())
+}
+
+// fieldName returns the fully-qualified name of field of messageDefinition.
+func fieldName(messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto) string {
+	return strings.TrimPrefix(messageDefinition.ProtoName(), ".") + "." + field.GetName()
+}
+
+func randomTimestamp() string {
+	randomTime := rand.Int63n(1000000000) + 94608000
+	randomNow := time.Unix(randomTime, 0)
+	return randomNow.Format(time.RFC3339)
+}
+
+func randomEnumValue(enum *descriptor.EnumDescriptorProto) string {
+	return enum.GetValue()[rand.Intn(len(enum.GetValue()))].GetName()
+}
+
+func checkEnumMessageMatch(enum *descriptor.EnumDescriptorProto, messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto) bool {
+	return field.GetTypeName() == fmt.Sprintf(".%s.%s.%s", messageDefinition.File.GetPackage(), messageDefinition.Descriptor.GetName(), enum.GetName())
+}
+
+func checkEnumFileMatch(enum *descriptor.EnumDescriptorProto, file *descriptor.FileDescriptorProto, field *descriptor.FieldDescriptorProto) bool {
+	return field.GetTypeName() == fmt.Sprintf(".%s.%s", file.GetPackage(), enum.GetName())
+}
+
+func randomString(n int) string {
+	var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+	}
+	return string(b)
+}
+
+// hints generate the values of string fields with a hint option. Like all
+// mock values, they're drawn from the seeded random source.
+var hints = map[string]func() string{
+	"uuid": func() string {
+		return fmt.Sprintf("%08x-%04x-4%03x-%04x-%012x",
+			rand.Uint32(), rand.Intn(1<<16), rand.Intn(1<<12), 0x8000|rand.Intn(1<<14), rand.Int63n(1<<48))
+	},
+	"email": func() string {
+		return strings.ToLower(randomString(8)) + "@example.com"
+	},
+	"url": func() string {
+		return "https://example.com/" + strings.ToLower(randomString(8))
+	},
+	"hostname": func() string {
+		return strings.ToLower(randomString(8)) + ".example.com"
+	},
+	"ipv4": func() string {
+		return fmt.Sprintf("10.%d.%d.%d", rand.Intn(256), rand.Intn(256), rand.Intn(256))
+	},
+	"phone": func() string {
+		return fmt.Sprintf("+1555%07d", rand.Intn(10000000))
+	},
+}
+
+// mockField is a field included in mocks, with its JSON key.
+type mockField struct {
+	key   string
+	field *descriptor.FieldDescriptorProto
+}
+
+// mockFields returns the fields of a message included in its mocks. Required
+// fields are always included, since messages without them can't be parsed.
+func (g *Generator) mockFields(messageDefinition *typemap.MessageDefinition) []mockField {
+	fields := []mockField{}
+	for _, field := range messageDefinition.Descriptor.Field {
+		if g.mocked(field) {
+			fields = append(fields, mockField{key: g.key(field), field: field})
+		}
+	}
+	if g.IncludeExtensions {
+		for _, ext := range g.Extensions(messageDefinition.ProtoName()) {
+			if g.mocked(ext.Field) {
+				fields = append(fields, mockField{key: "[" + ext.Name + "]", field: ext.Field})
+			}
+		}
+	}
+	return fields
+}
+
+// key returns the JSON key of field.
+func (g *Generator) key(field *descriptor.FieldDescriptorProto) string {
+	if g.OrigName {
+		return field.GetName()
+	}
+	return field.GetJsonName()
+}
+
+// mocked reports whether field is included in mocks.
+func (g *Generator) mocked(field *descriptor.FieldDescriptorProto) bool {
+	opts := options.Field(field)
+	switch g.presence.Of(field) {
+	case presence.Required:
+		return true
+	case presence.Explicit:
+		scalar := field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE && field.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP
+		inOneof := field.OneofIndex != nil && !presence.Proto3Optional(field)
+		if g.Presence == OmitPresence && scalar && !inOneof && opts.Example == "" {
+			return false
+		}
+	}
+	return !opts.Skip
+}
+
+// compactJSON returns the JSON value s without insignificant whitespace, so
+// that hand-written values don't disturb the indentation of mocks.
+func compactJSON(s string) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, []byte(s)); err != nil {
+		return s
+	}
+	return b.String()
+}
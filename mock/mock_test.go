@@ -0,0 +1,45 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// newTestGenerator returns a Generator for the file named name under
+// testdata, and the registry of its messages.
+func newTestGenerator(t *testing.T, name string) (*Generator, *typemap.Registry) {
+	t.Helper()
+	parser := protoparse.Parser{ImportPaths: []string{"testdata", filepath.Join("..", "proto")}}
+	files, err := parser.ParseFiles(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(files, nil), typemap.New(files)
+}
+
+// testMessage returns the definition of the message with the
+// fully-qualified name typeName.
+func testMessage(t *testing.T, registry *typemap.Registry, typeName string) *typemap.MessageDefinition {
+	t.Helper()
+	msg := registry.MessageDefinition(typeName)
+	if msg == nil {
+		t.Fatalf("message %s not found", typeName)
+	}
+	return msg
+}
@@ -0,0 +1,110 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// TestRecursiveMessages checks that messages within messages of the same
+// type are mocked as empty messages, through singular, repeated and map
+// fields, and through other messages.
+func TestRecursiveMessages(t *testing.T) {
+	g, registry := newTestGenerator(t, "recursive.proto")
+	for _, strategy := range []Strategy{Full, Minimal, Zero, Random} {
+		node := mustUnmarshal(t, g.MessageUsing(testMessage(t, registry, ".mocktest.recursive.Node"), strategy)).(map[string]interface{})
+		folder := mustUnmarshal(t, g.MessageUsing(testMessage(t, registry, ".mocktest.recursive.Folder"), strategy)).(map[string]interface{})
+		if strategy != Full {
+			continue
+		}
+		checkEmpty(t, "Node.parent", node["parent"])
+		for _, child := range node["children"].([]interface{}) {
+			checkEmpty(t, "Node.children[]", child)
+		}
+		for _, value := range node["index"].(map[string]interface{}) {
+			checkEmpty(t, "Node.index{}", value)
+		}
+		for _, file := range folder["files"].([]interface{}) {
+			checkEmpty(t, "Folder.files[].folder", file.(map[string]interface{})["folder"])
+		}
+	}
+}
+
+func checkEmpty(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	if obj, ok := v.(map[string]interface{}); !ok || len(obj) != 0 {
+		t.Errorf("%s: got %v, want an empty message", path, v)
+	}
+}
+
+// TestMaxDepth checks that messages nested deeper than maxDepth are mocked as
+// empty messages.
+func TestMaxDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var src strings.Builder
+	src.WriteString("syntax = \"proto3\";\npackage mocktest.depth;\n")
+	const levels = maxDepth + 10
+	for i := 0; i < levels; i++ {
+		fmt.Fprintf(&src, "message Level%d { Level%d next = 1; }\n", i, i+1)
+	}
+	fmt.Fprintf(&src, "message Level%d { string name = 1; }\n", levels)
+	if err := ioutil.WriteFile(filepath.Join(dir, "depth.proto"), []byte(src.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("depth.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := typemap.New(files)
+	mock := New(files, nil).Message(testMessage(t, registry, ".mocktest.depth.Level0"))
+
+	v := mustUnmarshal(t, mock)
+	depth := 0
+	for {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			t.Fatalf("level %d isn't an object: %v", depth, v)
+		}
+		if len(obj) == 0 {
+			break
+		}
+		v = obj["next"]
+		depth++
+	}
+	if depth != maxDepth+1 {
+		t.Errorf("mock nests %d messages, want %d", depth, maxDepth+1)
+	}
+}
+
+func mustUnmarshal(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+	return v
+}
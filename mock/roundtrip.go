@@ -0,0 +1,250 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// RoundTrip checks that body, a JSON message of type messageDefinition such
+// as a mock, is read and written back unchanged with the generator's
+// OrigName, EnumsAsInts and EmitDefaults, like the messages of clients using
+// those options of jsonpb.Marshaler. Fields left out of body may be written
+// back, but every value it holds must come back the same.
+//
+// This is a check of the generator against itself, driven by the
+// descriptors: body is read and encoded by Encode, and the encoding written
+// back as JSON by the generator's decoder, following the JSON mapping as
+// jsonpb implements it. jsonpb itself isn't used, since the vendored version
+// only reads and writes the Go types generated for messages, which the
+// messages of the files passed to protoc have none of. So it catches mocks
+// that don't match their descriptors, such as unknown fields, values of the
+// wrong type or enum names with EnumsAsInts, but not the differences
+// between the generator's reading of the mapping and jsonpb's.
+func (g *Generator) RoundTrip(messageDefinition *typemap.MessageDefinition, body string) error {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var value map[string]interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	if err := g.checkJSON(messageDefinition, value); err != nil {
+		return err
+	}
+	buf := proto.NewBuffer(nil)
+	if err := g.encodeMessage(buf, messageDefinition, value); err != nil {
+		return err
+	}
+	marshaler := &jsonpb.Marshaler{OrigName: g.OrigName, EnumsAsInts: g.EnumsAsInts, EmitDefaults: g.EmitDefaults}
+	written, err := g.decodeMessage(messageDefinition, buf.Bytes(), marshaler)
+	if err != nil {
+		return err
+	}
+	var after interface{}
+	dec = json.NewDecoder(strings.NewReader(written))
+	dec.UseNumber()
+	if err := dec.Decode(&after); err != nil {
+		return err
+	}
+	return roundTripped(value, after, "")
+}
+
+// roundTripped returns an error if the JSON value before, at path, isn't
+// the same as after, or after lacks any field of before.
+func roundTripped(before, after interface{}, path string) error {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(b))
+		for k := range b {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := strings.TrimPrefix(path+"."+k, ".")
+			v, ok := a[k]
+			if !ok {
+				if b[k] == nil {
+					continue
+				}
+				return fmt.Errorf("%s is written back without it", p)
+			}
+			if err := roundTripped(b[k], v, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range b {
+			if err := roundTripped(b[i], a[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.Number:
+		// Numbers are compared by value, since jsonpb writes them in their
+		// shortest form.
+		if a, ok := after.(json.Number); ok {
+			bf, err1 := b.Float64()
+			af, err2 := a.Float64()
+			if err1 == nil && err2 == nil && (bf == af || float32(bf) == float32(af)) {
+				return nil
+			}
+		}
+	default:
+		if before == after {
+			return nil
+		}
+	}
+	if path == "" {
+		path = "the message"
+	}
+	return fmt.Errorf("%s is written back as %s", path, compactValue(after))
+}
+
+func compactValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// checkJSON returns an error if value, the JSON of a message of type
+// messageDefinition, has a field its type has no field of, or holds a map or
+// bytes value the JSON mapping can't read. The other values are checked as
+// they're encoded.
+func (g *Generator) checkJSON(messageDefinition *typemap.MessageDefinition, value map[string]interface{}) error {
+	fields := map[string]*descriptor.FieldDescriptorProto{}
+	for _, field := range messageDefinition.Descriptor.Field {
+		fields[field.GetJsonName()] = field
+		fields[field.GetName()] = field
+	}
+	for _, ext := range g.Extensions(messageDefinition.ProtoName()) {
+		fields["["+ext.Name+"]"] = ext.Field
+	}
+	keys := make([]string, 0, len(value))
+	for k := range value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field, ok := fields[k]
+		if !ok {
+			return fmt.Errorf("unknown field %q in %s", k, strings.TrimPrefix(messageDefinition.ProtoName(), "."))
+		}
+		if err := g.checkFieldJSON(field, value[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) checkFieldJSON(field *descriptor.FieldDescriptorProto, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if entry := g.mapEntry(field); entry != nil {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field %s: expected an object, got %T", field.GetName(), v)
+		}
+		for _, value := range obj {
+			if err := g.checkValueJSON(entry.Descriptor.Field[1], value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return g.checkValueJSON(field, v)
+	}
+	elems, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("field %s: expected a list, got %T", field.GetName(), v)
+	}
+	for _, elem := range elems {
+		if err := g.checkValueJSON(field, elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) checkValueJSON(field *descriptor.FieldDescriptorProto, v interface{}) error {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		// jsonpb reads bytes as encoding/json does, in standard base64.
+		if s, ok := v.(string); ok {
+			if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+				return fmt.Errorf("field %s: %v", field.GetName(), err)
+			}
+		}
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if wellKnown(field.GetTypeName()) {
+			return nil
+		}
+		obj, ok := v.(map[string]interface{})
+		msg := g.registry.MessageDefinition(field.GetTypeName())
+		if ok && msg != nil {
+			return g.checkJSON(msg, obj)
+		}
+	}
+	return nil
+}
+
+// isDefault reports whether value, the JSON of field, is the default value
+// of its type, which jsonpb leaves out of messages unless EmitDefaults is
+// set.
+func (g *Generator) isDefault(field *descriptor.FieldDescriptorProto, value string) bool {
+	if g.mapEntry(field) != nil {
+		return value == "{}"
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return value == "[]"
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return value == `""`
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return value == "false"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if value == "0" {
+			return true
+		}
+		n, err := g.enumNumber(field.GetTypeName(), strings.Trim(value, `"`))
+		return err == nil && n == 0
+	}
+	f, err := strconv.ParseFloat(strings.Trim(value, `"`), 64)
+	return err == nil && f == 0
+}
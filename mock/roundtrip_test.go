@@ -0,0 +1,55 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	g, registry := newTestGenerator(t, "roundtrip.proto")
+	msg := testMessage(t, registry, ".mocktest.roundtrip.Order")
+	for _, strategy := range []Strategy{Full, Minimal, Zero, Random} {
+		if err := g.RoundTrip(msg, g.MessageUsing(msg, strategy)); err != nil {
+			t.Errorf("strategy %s: %v", strategy, err)
+		}
+	}
+
+	for _, c := range []struct {
+		body        string
+		enumsAsInts bool
+		want        string
+	}{
+		{body: `{"id": "a", "status": "OPEN", "item": {"sku": "b"}, "counts": {"x": "1"}}`},
+		{body: `{"status": 1}`, want: `status is written back as "OPEN"`},
+		{body: `{"status": 1}`, enumsAsInts: true},
+		{body: `{"id": "a", "unknown": 1}`, want: `unknown field "unknown" in mocktest.roundtrip.Order`},
+		{body: `{"item": {"name": "b"}}`, want: `unknown field "name" in mocktest.roundtrip.Item`},
+		{body: `{"receipt": "not base64!"}`, want: "field receipt:"},
+		{body: `{"id": 1}`, want: "field id: expected a string"},
+		{body: `{"status": "CLOSED"}`, want: "enum .mocktest.roundtrip.Order.Status has no value CLOSED"},
+		{body: `{"status": "OPEN"}`, enumsAsInts: true, want: "status is written back as 1"},
+		{body: `{"counts": {"x": "1.5"}}`, want: `field value: strconv.ParseInt: parsing "1.5"`},
+	} {
+		g.EnumsAsInts = c.enumsAsInts
+		err := g.RoundTrip(msg, c.body)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("RoundTrip(%s): %v", c.body, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("RoundTrip(%s): got error %v, want it to contain %q", c.body, err, c.want)
+		}
+	}
+}
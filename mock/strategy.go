@@ -0,0 +1,198 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/options"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+)
+
+// Strategy is how mocks choose which fields to populate, and with what.
+// Fields with an example are always mocked with it, and skipped fields are
+// always left out.
+type Strategy int
+
+const (
+	// Full mocks every field with a random value, and repeated fields with
+	// RepeatedCount elements.
+	Full Strategy = iota
+	// Minimal only mocks the fields a server would reject a message without:
+	// required fields, fields google.api.field_behavior marks REQUIRED, and
+	// fields with protoc-gen-validate or protovalidate rules. Repeated fields
+	// get a single element.
+	Minimal
+	// Zero mocks every field with its default value, written out explicitly:
+	// the default it declares, or else the zero value of its type. Repeated
+	// fields are empty.
+	Zero
+	// Random mocks each field with a random value, or leaves it out half the
+	// time unless it's required. Repeated fields get up to RepeatedCount
+	// elements.
+	Random
+)
+
+// DefaultRepeatedCount is the number of elements of repeated fields in the
+// mocks of the Full strategy, unless RepeatedCount is changed.
+const DefaultRepeatedCount = 3
+
+var strategyNames = map[Strategy]string{
+	Full:    "full",
+	Minimal: "minimal",
+	Zero:    "zero",
+	Random:  "random",
+}
+
+func (s Strategy) String() string {
+	if name, ok := strategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// ParseStrategy returns the strategy named name: "full", "minimal", "zero"
+// or "random".
+func ParseStrategy(name string) (Strategy, error) {
+	for s, n := range strategyNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return Full, fmt.Errorf("unknown mock strategy %q: expected one of full, minimal, zero or random", name)
+}
+
+// Validation options which make Minimal mock a field.
+var (
+	validateRules = &proto.ExtensionDesc{
+		ExtendedType:  (*descriptor.FieldOptions)(nil),
+		ExtensionType: ([]byte)(nil),
+		Field:         1071,
+		Name:          "validate.rules",
+		Tag:           "bytes,1071,opt,name=rules",
+	}
+	bufValidateField = &proto.ExtensionDesc{
+		ExtendedType:  (*descriptor.FieldOptions)(nil),
+		ExtensionType: ([]byte)(nil),
+		Field:         1159,
+		Name:          "buf.validate.field",
+		Tag:           "bytes,1159,opt,name=field",
+	}
+	fieldBehavior = &proto.ExtensionDesc{
+		ExtendedType:  (*descriptor.FieldOptions)(nil),
+		ExtensionType: ([]int32)(nil),
+		Field:         1052,
+		Name:          "google.api.field_behavior",
+		Tag:           "varint,1052,rep,name=field_behavior",
+	}
+)
+
+// fieldBehaviorRequired is the REQUIRED value of google.api.FieldBehavior.
+const fieldBehaviorRequired = 2
+
+// strategyFields returns the fields of fields which strategy mocks.
+func (g *Generator) strategyFields(fields []mockField, strategy Strategy) []mockField {
+	if strategy != Minimal && strategy != Random {
+		return fields
+	}
+	chosen := []mockField{}
+	for _, f := range fields {
+		keep := g.needed(f.field) || options.Field(f.field).Example != ""
+		if !keep && strategy == Random {
+			keep = rand.Intn(2) == 0
+		}
+		if keep {
+			chosen = append(chosen, f)
+		}
+	}
+	return chosen
+}
+
+// needed reports whether messages are invalid without field.
+func (g *Generator) needed(field *descriptor.FieldDescriptorProto) bool {
+	if g.presence.Of(field) == presence.Required {
+		return true
+	}
+	opts := field.GetOptions()
+	if opts == nil {
+		return false
+	}
+	if proto.HasExtension(opts, validateRules) || proto.HasExtension(opts, bufValidateField) {
+		return true
+	}
+	if !proto.HasExtension(opts, fieldBehavior) {
+		return false
+	}
+	behaviors, err := proto.GetExtension(opts, fieldBehavior)
+	if err != nil {
+		return false
+	}
+	for _, behavior := range behaviors.([]int32) {
+		if behavior == fieldBehaviorRequired {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatedCount returns the number of elements of a repeated field mocked
+// with strategy.
+func (g *Generator) repeatedCount(strategy Strategy) int {
+	switch strategy {
+	case Minimal:
+		return 1
+	case Zero:
+		return 0
+	case Random:
+		return rand.Intn(g.RepeatedCount + 1)
+	}
+	return g.RepeatedCount
+}
+
+// zeroValue returns the default value of field as JSON, or false if field is
+// a message, whose fields are mocked instead.
+func (g *Generator) zeroValue(field *descriptor.FieldDescriptorProto) (string, bool) {
+	switch field.GetTypeName() {
+	case ".google.protobuf.Timestamp":
+		return `"1970-01-01T00:00:00Z"`, true
+	case ".google.protobuf.Duration":
+		return `"0s"`, true
+	}
+	if field.DefaultValue != nil {
+		if value, err := g.defaultValue(field); err == nil {
+			return value, true
+		}
+	}
+
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return "", false
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "false", true
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return `""`, true
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// The default of an enum is its first value, which is also its zero
+		// value in proto3.
+		enum := g.Enum(field.GetTypeName())
+		if enum == nil || len(enum.Value) == 0 {
+			return "0", true
+		}
+		return jsonString(enum.Value[0].GetName()), true
+	}
+	return "0", true
+}
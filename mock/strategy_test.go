@@ -0,0 +1,143 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// requiredKeys are the fields of mocktest.strategies.Request which messages
+// are invalid without, along with the field with an example.
+var requiredKeys = []string{"behaviorRequired", "example", "id", "validated"}
+
+func TestFull(t *testing.T) {
+	fields := mockStrategy(t, Full, "Full")
+	want := []string{"behaviorOutput", "behaviorRequired", "color", "count", "example", "id", "inner", "plain", "tags", "validated"}
+	if got := sortedKeys(fields); !reflect.DeepEqual(got, want) {
+		t.Errorf("got fields %q, want %q", got, want)
+	}
+	var tags []string
+	decodeJSON(t, string(fields["tags"]), &tags)
+	if len(tags) != DefaultRepeatedCount {
+		t.Errorf("got %d tags, want %d", len(tags), DefaultRepeatedCount)
+	}
+	checkValue(t, fields, "example", `"hello"`)
+	checkValue(t, fields, "count", `42`)
+}
+
+func TestMinimal(t *testing.T) {
+	fields := mockStrategy(t, Minimal, "Minimal")
+	if got := sortedKeys(fields); !reflect.DeepEqual(got, requiredKeys) {
+		t.Errorf("got fields %q, want %q", got, requiredKeys)
+	}
+	checkValue(t, fields, "example", `"hello"`)
+}
+
+func TestZero(t *testing.T) {
+	fields := mockStrategy(t, Zero, "Zero")
+	for key, want := range map[string]string{
+		"id":      `""`,
+		"plain":   `""`,
+		"count":   `42`,
+		"tags":    `[]`,
+		"inner":   `{"flag":false}`,
+		"color":   `"RED"`,
+		"example": `"hello"`,
+	} {
+		checkValue(t, fields, key, want)
+	}
+	if _, ok := fields["skipped"]; ok {
+		t.Error("skipped field was mocked")
+	}
+}
+
+func TestRandom(t *testing.T) {
+	seen := map[string]int{}
+	const runs = 20
+	for i := 0; i < runs; i++ {
+		fields := mockStrategy(t, Random, fmt.Sprintf("Random%d", i))
+		for _, key := range requiredKeys {
+			if _, ok := fields[key]; !ok {
+				t.Errorf("run %d: required field %s was left out", i, key)
+			}
+		}
+		if _, ok := fields["skipped"]; ok {
+			t.Errorf("run %d: skipped field was mocked", i)
+		}
+		if tags, ok := fields["tags"]; ok {
+			var values []string
+			decodeJSON(t, string(tags), &values)
+			if len(values) > DefaultRepeatedCount {
+				t.Errorf("run %d: got %d tags, want at most %d", i, len(values), DefaultRepeatedCount)
+			}
+		}
+		for key := range fields {
+			seen[key]++
+		}
+	}
+	if n := seen["plain"]; n == 0 || n == runs {
+		t.Errorf("optional field was mocked in %d of %d runs, want some of them", n, runs)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range []Strategy{Full, Minimal, Zero, Random} {
+		got, err := ParseStrategy(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseStrategy(%q) = %v, %v, want %v", s.String(), got, err, s)
+		}
+	}
+	if _, err := ParseStrategy("most"); err == nil {
+		t.Error("ParseStrategy accepted an unknown strategy")
+	}
+}
+
+// mockStrategy mocks mocktest.strategies.Request with strategy, seeded with
+// seed, and returns its fields.
+func mockStrategy(t *testing.T, strategy Strategy, seed string) map[string]json.RawMessage {
+	t.Helper()
+	g, registry := newTestGenerator(t, "strategies.proto")
+	Seed(seed)
+	var fields map[string]json.RawMessage
+	decodeJSON(t, g.MessageUsing(testMessage(t, registry, ".mocktest.strategies.Request"), strategy), &fields)
+	return fields
+}
+
+// checkValue checks the value of the field key, ignoring whitespace.
+func checkValue(t *testing.T, fields map[string]json.RawMessage, key, want string) {
+	t.Helper()
+	if got := compactJSON(string(fields[key])); got != want {
+		t.Errorf("got %s %s, want %s", key, got, want)
+	}
+}
+
+func sortedKeys(fields map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func decodeJSON(t *testing.T, s string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+}
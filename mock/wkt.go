@@ -0,0 +1,482 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// The well-known types which jsonpb gives JSON forms of their own. Other
+// well-known types, like google.protobuf.FieldMask, are written like any
+// other message.
+const (
+	timestampType = ".google.protobuf.Timestamp"
+	durationType  = ".google.protobuf.Duration"
+	anyType       = ".google.protobuf.Any"
+	structType    = ".google.protobuf.Struct"
+	valueType     = ".google.protobuf.Value"
+	listValueType = ".google.protobuf.ListValue"
+)
+
+// wrapperTypes are the types of the values held by the wrapper types, which
+// are written as the values they hold.
+var wrapperTypes = map[string]descriptor.FieldDescriptorProto_Type{
+	".google.protobuf.DoubleValue": descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+	".google.protobuf.FloatValue":  descriptor.FieldDescriptorProto_TYPE_FLOAT,
+	".google.protobuf.Int64Value":  descriptor.FieldDescriptorProto_TYPE_INT64,
+	".google.protobuf.UInt64Value": descriptor.FieldDescriptorProto_TYPE_UINT64,
+	".google.protobuf.Int32Value":  descriptor.FieldDescriptorProto_TYPE_INT32,
+	".google.protobuf.UInt32Value": descriptor.FieldDescriptorProto_TYPE_UINT32,
+	".google.protobuf.BoolValue":   descriptor.FieldDescriptorProto_TYPE_BOOL,
+	".google.protobuf.StringValue": descriptor.FieldDescriptorProto_TYPE_STRING,
+	".google.protobuf.BytesValue":  descriptor.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// typeURLPrefix is the prefix of the type URLs of the messages held by
+// google.protobuf.Any.
+const typeURLPrefix = "type.googleapis.com/"
+
+// wrappedField returns the value field of the wrapper type typeName, named
+// like the field holding the wrapper, or nil if typeName isn't a wrapper
+// type.
+func wrappedField(name, typeName string) *descriptor.FieldDescriptorProto {
+	t, ok := wrapperTypes[typeName]
+	if !ok {
+		return nil
+	}
+	return &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String("value"),
+		Number:   proto.Int32(1),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     t.Enum(),
+	}
+}
+
+// wellKnown reports whether typeName is a well-known type with a JSON form
+// of its own.
+func wellKnown(typeName string) bool {
+	switch typeName {
+	case timestampType, durationType, anyType, structType, valueType, listValueType:
+		return true
+	}
+	return wrapperTypes[typeName] != 0
+}
+
+// wellKnownValue returns a mock of field in the JSON form of its type, or
+// false if its type isn't a well-known type with a JSON form of its own.
+func (g *Generator) wellKnownValue(messageDefinition *typemap.MessageDefinition, field *descriptor.FieldDescriptorProto, depth int, strategy Strategy) (string, bool) {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return "", false
+	}
+	// Like the fields of nested messages, the contents of objects and lists
+	// are indented twice more than the field holding them.
+	indent := strings.Repeat("\t", depth+2)
+	switch field.GetTypeName() {
+	case timestampType:
+		return fmt.Sprintf("\"%s\"", randomTimestamp()), true
+	case durationType:
+		return randomDuration(), true
+	case anyType:
+		// Any holds a Duration, which every server can resolve.
+		duration := `"0s"`
+		if strategy != Zero {
+			duration = randomDuration()
+		}
+		return "{\n" +
+			indent + `"@type": "` + typeURLPrefix + strings.TrimPrefix(durationType, ".") + "\",\n" +
+			indent + `"value": ` + duration + "\n" +
+			strings.Repeat("\t", depth+1) + "}", true
+	case structType:
+		entries := []string{}
+		for i := g.repeatedCount(strategy); i > 0; i-- {
+			entries = append(entries, indent+jsonString(strings.ToLower(randomString(8)))+": "+jsonString(randomString(10)))
+		}
+		if len(entries) == 0 {
+			return "{}", true
+		}
+		return "{\n" + strings.Join(entries, ",\n") + "\n" + strings.Repeat("\t", depth+1) + "}", true
+	case valueType:
+		if strategy == Zero {
+			return "null", true
+		}
+		return jsonString(randomString(10)), true
+	case listValueType:
+		elems := []string{}
+		for i := g.repeatedCount(strategy); i > 0; i-- {
+			elems = append(elems, indent+jsonString(randomString(10)))
+		}
+		if len(elems) == 0 {
+			return "[]", true
+		}
+		return "[\n" + strings.Join(elems, ",\n") + "\n" + strings.Repeat("\t", depth+1) + "]", true
+	}
+	if wrapped := wrappedField(field.GetName(), field.GetTypeName()); wrapped != nil {
+		return g.value(messageDefinition, wrapped, depth, strategy), true
+	}
+	return "", false
+}
+
+// randomDuration returns a random duration, with as many fractional digits
+// as jsonpb writes.
+func randomDuration() string {
+	return fmt.Sprintf("\"%ss\"", trimNanos(fmt.Sprintf("%d.%09d", rand.Intn(1000), rand.Intn(100)*1000000)))
+}
+
+// encodeWellKnown writes v, the JSON form of the well-known type typeName of
+// the field name, to buf. It returns false if typeName isn't a well-known
+// type with a JSON form of its own.
+func (g *Generator) encodeWellKnown(buf *proto.Buffer, name, typeName string, v interface{}) (bool, error) {
+	switch typeName {
+	case timestampType:
+		s, _ := v.(string)
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return true, fmt.Errorf("field %s: %v", name, err)
+		}
+		encodeSecondsNanos(buf, t.Unix(), int64(t.Nanosecond()))
+		return true, nil
+	case durationType:
+		s, _ := v.(string)
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return true, fmt.Errorf("field %s: %v", name, err)
+		}
+		encodeSecondsNanos(buf, int64(d/time.Second), int64(d%time.Second))
+		return true, nil
+	case anyType:
+		return true, g.encodeAny(buf, name, v)
+	case structType:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return true, fmt.Errorf("field %s: expected an object, got %T", name, v)
+		}
+		return true, encodeStruct(buf, obj)
+	case valueType:
+		return true, encodeStructValue(buf, v)
+	case listValueType:
+		list, ok := v.([]interface{})
+		if !ok {
+			return true, fmt.Errorf("field %s: expected a list, got %T", name, v)
+		}
+		return true, encodeListValue(buf, list)
+	}
+	if wrapped := wrappedField(name, typeName); wrapped != nil {
+		return true, g.encodeField(buf, wrapped, v)
+	}
+	return false, nil
+}
+
+// encodeAny writes v, the JSON form of google.protobuf.Any: the fields of
+// the message it holds, or the JSON form of a well-known type under "value",
+// along with its type URL under "@type".
+func (g *Generator) encodeAny(buf *proto.Buffer, name string, v interface{}) error {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("field %s: expected an object, got %T", name, v)
+	}
+	typeURL, _ := obj["@type"].(string)
+	if typeURL == "" {
+		return fmt.Errorf("field %s: Any has no @type", name)
+	}
+	typeName := "." + typeURL[strings.LastIndex(typeURL, "/")+1:]
+	inner := proto.NewBuffer(nil)
+	if strings.HasPrefix(typeName, ".google.protobuf.") {
+		ok, err := g.encodeWellKnown(inner, name, typeName, obj["value"])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			value, _ := obj["value"].(map[string]interface{})
+			if err := g.encodeAnyMessage(inner, name, typeName, value); err != nil {
+				return err
+			}
+		}
+	} else {
+		fields := map[string]interface{}{}
+		for k, v := range obj {
+			if k != "@type" {
+				fields[k] = v
+			}
+		}
+		if err := g.encodeAnyMessage(inner, name, typeName, fields); err != nil {
+			return err
+		}
+	}
+	buf.EncodeVarint(1<<3 | proto.WireBytes)
+	buf.EncodeStringBytes(typeURL)
+	buf.EncodeVarint(2<<3 | proto.WireBytes)
+	return buf.EncodeRawBytes(inner.Bytes())
+}
+
+func (g *Generator) encodeAnyMessage(buf *proto.Buffer, name, typeName string, value map[string]interface{}) error {
+	msg := g.registry.MessageDefinition(typeName)
+	if msg == nil {
+		return fmt.Errorf("field %s: message %s held by Any could not be found", name, typeName)
+	}
+	return g.encodeMessage(buf, msg, value)
+}
+
+// encodeStruct, encodeStructValue and encodeListValue write the JSON forms of
+// google.protobuf.Struct, Value and ListValue. The fields of structs are
+// written in the order of their keys, so that encodings are stable.
+func encodeStruct(buf *proto.Buffer, obj map[string]interface{}) error {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := proto.NewBuffer(nil)
+		if err := encodeStructValue(value, obj[k]); err != nil {
+			return err
+		}
+		entry := proto.NewBuffer(nil)
+		entry.EncodeVarint(1<<3 | proto.WireBytes)
+		entry.EncodeStringBytes(k)
+		entry.EncodeVarint(2<<3 | proto.WireBytes)
+		entry.EncodeRawBytes(value.Bytes())
+		buf.EncodeVarint(1<<3 | proto.WireBytes)
+		buf.EncodeRawBytes(entry.Bytes())
+	}
+	return nil
+}
+
+func encodeStructValue(buf *proto.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.EncodeVarint(1<<3 | proto.WireVarint)
+		return buf.EncodeVarint(0)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.EncodeVarint(2<<3 | proto.WireFixed64)
+		return buf.EncodeFixed64(math.Float64bits(f))
+	case string:
+		buf.EncodeVarint(3<<3 | proto.WireBytes)
+		return buf.EncodeStringBytes(v)
+	case bool:
+		buf.EncodeVarint(4<<3 | proto.WireVarint)
+		if v {
+			return buf.EncodeVarint(1)
+		}
+		return buf.EncodeVarint(0)
+	case map[string]interface{}:
+		inner := proto.NewBuffer(nil)
+		if err := encodeStruct(inner, v); err != nil {
+			return err
+		}
+		buf.EncodeVarint(5<<3 | proto.WireBytes)
+		return buf.EncodeRawBytes(inner.Bytes())
+	case []interface{}:
+		inner := proto.NewBuffer(nil)
+		if err := encodeListValue(inner, v); err != nil {
+			return err
+		}
+		buf.EncodeVarint(6<<3 | proto.WireBytes)
+		return buf.EncodeRawBytes(inner.Bytes())
+	}
+	return fmt.Errorf("unexpected JSON value %T", v)
+}
+
+func encodeListValue(buf *proto.Buffer, list []interface{}) error {
+	for _, elem := range list {
+		value := proto.NewBuffer(nil)
+		if err := encodeStructValue(value, elem); err != nil {
+			return err
+		}
+		buf.EncodeVarint(1<<3 | proto.WireBytes)
+		buf.EncodeRawBytes(value.Bytes())
+	}
+	return nil
+}
+
+// decodeWellKnown returns the message of the well-known type typeName
+// encoded in b in its JSON form, as m writes it. It returns false if
+// typeName isn't a well-known type with a JSON form of its own.
+func (g *Generator) decodeWellKnown(typeName string, b []byte, m *jsonpb.Marshaler) (string, bool, error) {
+	if !wellKnown(typeName) {
+		return "", false, nil
+	}
+	fields, err := parseFields(b)
+	if err != nil {
+		return "", true, err
+	}
+	switch typeName {
+	case timestampType:
+		seconds, nanos := fields.varint(1), fields.varint(2)
+		t := time.Unix(int64(seconds), int64(int32(nanos))).UTC()
+		return `"` + trimNanos(t.Format("2006-01-02T15:04:05.000000000")) + `Z"`, true, nil
+	case durationType:
+		seconds, nanos := int64(fields.varint(1)), int64(int32(fields.varint(2)))
+		format := "%d.%09d"
+		if nanos < 0 {
+			nanos = -nanos
+			if seconds == 0 {
+				format = "-%d.%09d"
+			}
+		}
+		return `"` + trimNanos(fmt.Sprintf(format, seconds, nanos)) + `s"`, true, nil
+	case anyType:
+		s, err := g.decodeAny(fields, m)
+		return s, true, err
+	case structType:
+		s, err := decodeStruct(fields)
+		return s, true, err
+	case valueType:
+		s, err := decodeStructValue(fields)
+		return s, true, err
+	case listValueType:
+		s, err := decodeListValue(fields)
+		return s, true, err
+	}
+	wrapped := wrappedField("value", typeName)
+	s, err := g.decodeScalar(wrapped, fields.last(wrapped), m)
+	return s, true, err
+}
+
+// trimNanos trims the trailing zeros of the nine fractional digits of
+// timestamps and durations to leave 0, 3 or 6 of them, as jsonpb does.
+func trimNanos(s string) string {
+	s = strings.TrimSuffix(s, "000")
+	s = strings.TrimSuffix(s, "000")
+	return strings.TrimSuffix(s, ".000")
+}
+
+func (g *Generator) decodeAny(fields rawFields, m *jsonpb.Marshaler) (string, error) {
+	var typeURL string
+	var value []byte
+	if f := fields[1]; len(f) > 0 {
+		typeURL = string(f[len(f)-1].bytes)
+	}
+	for _, f := range fields[2] {
+		value = append(value, f.bytes...)
+	}
+	if typeURL == "" {
+		return "{}", nil
+	}
+	typeName := "." + typeURL[strings.LastIndex(typeURL, "/")+1:]
+	if s, ok, err := g.decodeWellKnown(typeName, value, m); ok || err != nil {
+		return `{"@type":` + jsonString(typeURL) + `,"value":` + s + "}", err
+	}
+	msg := g.registry.MessageDefinition(typeName)
+	if msg == nil {
+		return "", fmt.Errorf("message %s held by Any could not be found", typeName)
+	}
+	s, err := g.decodeMessage(msg, value, m)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(typeName, ".google.protobuf.") {
+		return `{"@type":` + jsonString(typeURL) + `,"value":` + s + "}", nil
+	}
+	if s == "{}" {
+		return `{"@type":` + jsonString(typeURL) + "}", nil
+	}
+	return `{"@type":` + jsonString(typeURL) + "," + s[1:], nil
+}
+
+func decodeStruct(fields rawFields) (string, error) {
+	values := map[string]string{}
+	for _, f := range fields[1] {
+		entry, err := parseFields(f.bytes)
+		if err != nil {
+			return "", err
+		}
+		var key string
+		if k := entry[1]; len(k) > 0 {
+			key = string(k[len(k)-1].bytes)
+		}
+		var b []byte
+		for _, v := range entry[2] {
+			b = append(b, v.bytes...)
+		}
+		value, err := parseFields(b)
+		if err != nil {
+			return "", err
+		}
+		if values[key], err = decodeStructValue(value); err != nil {
+			return "", err
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = jsonString(k) + ":" + values[k]
+	}
+	return "{" + strings.Join(entries, ",") + "}", nil
+}
+
+func decodeStructValue(fields rawFields) (string, error) {
+	for number := int32(1); number <= 6; number++ {
+		f := fields[number]
+		if len(f) == 0 {
+			continue
+		}
+		last := f[len(f)-1]
+		switch number {
+		case 1:
+			return "null", nil
+		case 2:
+			return jsonFloatString(math.Float64frombits(last.value), 64), nil
+		case 3:
+			return jsonString(string(last.bytes)), nil
+		case 4:
+			if last.value != 0 {
+				return "true", nil
+			}
+			return "false", nil
+		}
+		inner, err := parseFields(last.bytes)
+		if err != nil {
+			return "", err
+		}
+		if number == 5 {
+			return decodeStruct(inner)
+		}
+		return decodeListValue(inner)
+	}
+	return "", fmt.Errorf("nil Value")
+}
+
+func decodeListValue(fields rawFields) (string, error) {
+	elems := make([]string, len(fields[1]))
+	for i, f := range fields[1] {
+		value, err := parseFields(f.bytes)
+		if err != nil {
+			return "", err
+		}
+		if elems[i], err = decodeStructValue(value); err != nil {
+			return "", err
+		}
+	}
+	return "[" + strings.Join(elems, ",") + "]", nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package options holds the Go counterparts of the options declared in
+// proto/insomnia/options.proto, which configure the requests generated for
+// services, methods and fields.
+package options
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// The types and extensions in this file must be kept in sync with
+// proto/insomnia/options.proto.
+
+// ServiceOptions configure the requests generated for a service.
+type ServiceOptions struct {
+	// Path the service is mounted under, overriding the path_prefix parameter.
+	// Use "/" to mount the service at the root.
+	PathPrefix string `protobuf:"bytes,1,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"`
+	// Authentication of the service's requests, overriding the auth parameter.
+	Auth *Auth `protobuf:"bytes,2,opt,name=auth,proto3" json:"auth,omitempty"`
+	// Headers added to the service's requests, after those of the headers
+	// parameter.
+	Headers []*Header `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty"`
+	// Environment variable holding the base URL of the service's requests,
+	// instead of base_url.
+	BaseUrlVariable string `protobuf:"bytes,4,opt,name=base_url_variable,json=baseUrlVariable,proto3" json:"base_url_variable,omitempty"`
+	// Name of the folder holding the service's requests, instead of the name
+	// of the service.
+	Folder               string   `protobuf:"bytes,5,opt,name=folder,proto3" json:"folder,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ServiceOptions) Reset()         { *m = ServiceOptions{} }
+func (m *ServiceOptions) String() string { return proto.CompactTextString(m) }
+func (*ServiceOptions) ProtoMessage()    {}
+
+// MethodOptions configure the request generated for a method.
+type MethodOptions struct {
+	// Authentication of the method's request, overriding the service's.
+	Auth *Auth `protobuf:"bytes,1,opt,name=auth,proto3" json:"auth,omitempty"`
+	// Headers added to the method's request, after those of its service.
+	Headers []*Header `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	// Leave the method out of the generated workspace.
+	Skip bool `protobuf:"varint,3,opt,name=skip,proto3" json:"skip,omitempty"`
+	// Additional requests to the method, each sending its own body.
+	Examples []*Example `protobuf:"bytes,4,rep,name=examples,proto3" json:"examples,omitempty"`
+	// Name of a folder within the service's folder holding the method's
+	// requests.
+	Folder string `protobuf:"bytes,5,opt,name=folder,proto3" json:"folder,omitempty"`
+	// How the method's request body and example response are mocked,
+	// overriding the mock_strategy parameter.
+	MockStrategy         string   `protobuf:"bytes,6,opt,name=mock_strategy,json=mockStrategy,proto3" json:"mock_strategy,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MethodOptions) Reset()         { *m = MethodOptions{} }
+func (m *MethodOptions) String() string { return proto.CompactTextString(m) }
+func (*MethodOptions) ProtoMessage()    {}
+
+// FieldOptions configure how a field is mocked.
+type FieldOptions struct {
+	// JSON value used for the field instead of a random one.
+	Example string `protobuf:"bytes,1,opt,name=example,proto3" json:"example,omitempty"`
+	// Leave the field out of mock messages.
+	Skip bool `protobuf:"varint,2,opt,name=skip,proto3" json:"skip,omitempty"`
+	// Kind of value generated for a string field.
+	Hint                 string   `protobuf:"bytes,3,opt,name=hint,proto3" json:"hint,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FieldOptions) Reset()         { *m = FieldOptions{} }
+func (m *FieldOptions) String() string { return proto.CompactTextString(m) }
+func (*FieldOptions) ProtoMessage()    {}
+
+// Example is a request to a method with a hand-written body.
+type Example struct {
+	// Name of the request, which is shown after the method's name.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Body of the request, as JSON.
+	Body                 string   `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Example) Reset()         { *m = Example{} }
+func (m *Example) String() string { return proto.CompactTextString(m) }
+func (*Example) ProtoMessage()    {}
+
+// Auth configures how requests authenticate.
+type Auth struct {
+	// One of "none", "bearer", "apikey", "basic" or "oauth2".
+	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// Header carrying the key of "apikey" authentication, overriding the
+	// api_key_header parameter.
+	ApiKeyHeader         string   `protobuf:"bytes,2,opt,name=api_key_header,json=apiKeyHeader,proto3" json:"api_key_header,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Auth) Reset()         { *m = Auth{} }
+func (m *Auth) String() string { return proto.CompactTextString(m) }
+func (*Auth) ProtoMessage()    {}
+
+// Header is a header added to requests.
+type Header struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Header) Reset()         { *m = Header{} }
+func (m *Header) String() string { return proto.CompactTextString(m) }
+func (*Header) ProtoMessage()    {}
+
+// E_Service is the (insomnia.service) option.
+var E_Service = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.ServiceOptions)(nil),
+	ExtensionType: (*ServiceOptions)(nil),
+	Field:         51230,
+	Name:          "insomnia.service",
+	Tag:           "bytes,51230,opt,name=service",
+	Filename:      "insomnia/options.proto",
+}
+
+// E_Method is the (insomnia.method) option.
+var E_Method = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.MethodOptions)(nil),
+	ExtensionType: (*MethodOptions)(nil),
+	Field:         51231,
+	Name:          "insomnia.method",
+	Tag:           "bytes,51231,opt,name=method",
+	Filename:      "insomnia/options.proto",
+}
+
+// E_Field is the (insomnia.field) option.
+var E_Field = &proto.ExtensionDesc{
+	ExtendedType:  (*descriptor.FieldOptions)(nil),
+	ExtensionType: (*FieldOptions)(nil),
+	Field:         51232,
+	Name:          "insomnia.field",
+	Tag:           "bytes,51232,opt,name=field",
+	Filename:      "insomnia/options.proto",
+}
+
+func init() {
+	proto.RegisterType((*ServiceOptions)(nil), "insomnia.ServiceOptions")
+	proto.RegisterType((*MethodOptions)(nil), "insomnia.MethodOptions")
+	proto.RegisterType((*FieldOptions)(nil), "insomnia.FieldOptions")
+	proto.RegisterType((*Example)(nil), "insomnia.Example")
+	proto.RegisterType((*Auth)(nil), "insomnia.Auth")
+	proto.RegisterType((*Header)(nil), "insomnia.Header")
+	proto.RegisterExtension(E_Service)
+	proto.RegisterExtension(E_Method)
+	proto.RegisterExtension(E_Field)
+}
+
+// Service returns the (insomnia.service) option set on service, or an
+// empty set of options if there is none.
+func Service(service *descriptor.ServiceDescriptorProto) *ServiceOptions {
+	if service.GetOptions() == nil {
+		return &ServiceOptions{}
+	}
+	ext, err := proto.GetExtension(service.GetOptions(), E_Service)
+	if err != nil {
+		return &ServiceOptions{}
+	}
+	return ext.(*ServiceOptions)
+}
+
+// Method returns the (insomnia.method) option set on method, or an
+// empty set of options if there is none.
+func Method(method *descriptor.MethodDescriptorProto) *MethodOptions {
+	if method.GetOptions() == nil {
+		return &MethodOptions{}
+	}
+	ext, err := proto.GetExtension(method.GetOptions(), E_Method)
+	if err != nil {
+		return &MethodOptions{}
+	}
+	return ext.(*MethodOptions)
+}
+
+// Field returns the (insomnia.field) option set on field, or an empty
+// set of options if there is none.
+func Field(field *descriptor.FieldDescriptorProto) *FieldOptions {
+	if field.GetOptions() == nil {
+		return &FieldOptions{}
+	}
+	ext, err := proto.GetExtension(field.GetOptions(), E_Field)
+	if err != nil {
+		return &FieldOptions{}
+	}
+	return ext.(*FieldOptions)
+}
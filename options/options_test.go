@@ -0,0 +1,137 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+// TestInSyncWithProto checks that the types and extensions of this package
+// declare the same fields as proto/insomnia/options.proto.
+func TestInSyncWithProto(t *testing.T) {
+	files, err := protoparse.Parser{ImportPaths: []string{filepath.Join("..", "proto")}}.ParseFiles("insomnia/options.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := files[len(files)-1]
+
+	for _, msg := range file.MessageType {
+		typ := proto.MessageType("insomnia." + msg.GetName())
+		if typ == nil {
+			t.Errorf("insomnia.%s has no Go type", msg.GetName())
+			continue
+		}
+		props := map[string]*proto.Properties{}
+		for _, p := range proto.GetProperties(typ.Elem()).Prop {
+			if p.Tag > 0 {
+				props[p.OrigName] = p
+			}
+		}
+		for _, field := range msg.Field {
+			p, ok := props[field.GetName()]
+			if !ok {
+				t.Errorf("insomnia.%s.%s has no Go field", msg.GetName(), field.GetName())
+				continue
+			}
+			delete(props, field.GetName())
+			repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+			if p.Tag != int(field.GetNumber()) || p.Repeated != repeated {
+				t.Errorf("insomnia.%s.%s: got Go field numbered %d (repeated: %t), want %d (repeated: %t)", msg.GetName(), field.GetName(), p.Tag, p.Repeated, field.GetNumber(), repeated)
+			}
+		}
+		for name := range props {
+			t.Errorf("insomnia.%s.%s isn't declared in options.proto", msg.GetName(), name)
+		}
+	}
+
+	extensions := map[string]*proto.ExtensionDesc{
+		".google.protobuf.ServiceOptions": E_Service,
+		".google.protobuf.MethodOptions":  E_Method,
+		".google.protobuf.FieldOptions":   E_Field,
+	}
+	for _, ext := range file.Extension {
+		desc, ok := extensions[ext.GetExtendee()]
+		if !ok {
+			t.Errorf("extension %s of %s has no Go counterpart", ext.GetName(), ext.GetExtendee())
+			continue
+		}
+		if desc.Field != ext.GetNumber() || desc.Name != "insomnia."+ext.GetName() || reflect.TypeOf(desc.ExtensionType) != proto.MessageType(ext.GetTypeName()[1:]) {
+			t.Errorf("extension %s: got %s numbered %d, want %d", ext.GetName(), desc.Name, desc.Field, ext.GetNumber())
+		}
+	}
+}
+
+func TestReadOptions(t *testing.T) {
+	file := parseFile(t, `syntax = "proto3";
+package test;
+import "insomnia/options.proto";
+message Request {
+  string id = 1 [(insomnia.field) = {example: "\"a\"", hint: "uuid"}];
+  string plain = 2;
+}
+service Things {
+  option (insomnia.service) = {path_prefix: "/api", headers: [{name: "X-A", value: "1"}]};
+  rpc Get(Request) returns (Request) {
+    option (insomnia.method) = {
+      folder: "reads"
+      examples: {name: "empty", body: "{}"}
+    };
+  }
+  rpc Put(Request) returns (Request);
+}
+`)
+	service := file.Service[0]
+	if got := Service(service); got.PathPrefix != "/api" || len(got.Headers) != 1 || got.Headers[0].Name != "X-A" || got.Headers[0].Value != "1" {
+		t.Errorf("got service options %v", got)
+	}
+	if got := Method(service.Method[0]); got.Folder != "reads" || len(got.Examples) != 1 || got.Examples[0].Name != "empty" || got.Examples[0].Body != "{}" {
+		t.Errorf("got method options %v", got)
+	}
+	if got := Field(file.MessageType[0].Field[0]); got.Example != `"a"` || got.Hint != "uuid" {
+		t.Errorf("got field options %v", got)
+	}
+
+	// Definitions without options get empty options.
+	if got := Method(service.Method[1]); got == nil || !proto.Equal(got, &MethodOptions{}) {
+		t.Errorf("got method options %v, want empty options", got)
+	}
+	if got := Field(file.MessageType[0].Field[1]); got == nil || !proto.Equal(got, &FieldOptions{}) {
+		t.Errorf("got field options %v, want empty options", got)
+	}
+}
+
+func parseFile(t *testing.T, src string) *descriptor.FileDescriptorProto {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.proto"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := protoparse.Parser{ImportPaths: []string{dir, filepath.Join("..", "proto")}}.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files[len(files)-1]
+}
@@ -0,0 +1,345 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package presence tells which fields of a set of proto files track whether
+// they're set, whether through proto2 labels, proto3 optional or the
+// field_presence feature of editions. It also tells which message fields are
+// delimited, encoded as groups, whether through proto2 groups or the
+// message_encoding feature of editions.
+//
+// The descriptors this repository vendors predate proto3 optional and
+// editions, so the fields describing them are read from the unknown fields
+// of the descriptors, where protoc's values end up.
+package presence
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Presence is how a field tracks whether it's set.
+type Presence int
+
+const (
+	// Implicit fields are unset when they hold their zero value, like the
+	// singular scalar fields of proto3. Repeated fields are implicit too.
+	Implicit Presence = iota
+	// Explicit fields are set or unset independently of their value, like
+	// proto2 optional fields, proto3 optional fields, message fields and the
+	// fields of oneofs.
+	Explicit
+	// Required fields must always be set, like proto2 required fields.
+	Required
+)
+
+// Field numbers of the descriptor fields the vendored descriptors lack.
+const (
+	fileEditionNumber            = 14
+	fieldProto3OptionalNumber    = 17
+	fileOptionsFeaturesNumber    = 50
+	messageOptionsFeaturesNumber = 12
+	fieldOptionsFeaturesNumber   = 21
+	featureFieldPresenceNumber   = 1
+	featureMessageEncodingNumber = 5
+)
+
+// Values of FeatureSet.FieldPresence.
+const (
+	fieldPresenceUnknown = iota
+	fieldPresenceExplicit
+	fieldPresenceImplicit
+	fieldPresenceLegacyRequired
+)
+
+// Values of FeatureSet.MessageEncoding.
+const (
+	messageEncodingUnknown = iota
+	messageEncodingLengthPrefixed
+	messageEncodingDelimited
+)
+
+// Edition2023 is the number of the first edition, which is also the only
+// one supported.
+const Edition2023 = 1000
+
+// Index records the presence of the fields of a set of files.
+type Index struct {
+	presence  map[*descriptor.FieldDescriptorProto]Presence
+	delimited map[*descriptor.FieldDescriptorProto]bool
+}
+
+// New returns the presence of the fields, including extensions, of files.
+func New(files []*descriptor.FileDescriptorProto) *Index {
+	ix := &Index{
+		presence:  map[*descriptor.FieldDescriptorProto]Presence{},
+		delimited: map[*descriptor.FieldDescriptorProto]bool{},
+	}
+	for _, file := range files {
+		ix.addFile(file)
+	}
+	return ix
+}
+
+// Of returns the presence of field. Fields which aren't part of the files
+// are resolved from their label and type alone, as in proto2.
+func (ix *Index) Of(field *descriptor.FieldDescriptorProto) Presence {
+	if ix != nil {
+		if p, ok := ix.presence[field]; ok {
+			return p
+		}
+	}
+	return labelPresence(field, "proto2")
+}
+
+// Delimited reports whether field is a message field encoded as a group,
+// between start and end group tags, rather than prefixed with its length.
+// Fields which aren't part of the files are delimited if they're groups.
+func (ix *Index) Delimited(field *descriptor.FieldDescriptorProto) bool {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		return true
+	}
+	return ix != nil && ix.delimited[field]
+}
+
+func (ix *Index) addFile(file *descriptor.FileDescriptorProto) {
+	if !Editions(file) {
+		var addMessage func(msg *descriptor.DescriptorProto)
+		addMessage = func(msg *descriptor.DescriptorProto) {
+			for _, fields := range [][]*descriptor.FieldDescriptorProto{msg.Field, msg.Extension} {
+				for _, field := range fields {
+					ix.presence[field] = labelPresence(field, file.GetSyntax())
+				}
+			}
+			for _, nested := range msg.NestedType {
+				addMessage(nested)
+			}
+		}
+		for _, field := range file.Extension {
+			ix.presence[field] = labelPresence(field, file.GetSyntax())
+		}
+		for _, msg := range file.MessageType {
+			addMessage(msg)
+		}
+		return
+	}
+
+	// Features are inherited from the enclosing definitions, the file's
+	// defaulting to those of its edition.
+	fileFeatures := featureValues(fileOptions(file), fileOptionsFeaturesNumber, features{
+		presence: fieldPresenceExplicit,
+		encoding: messageEncodingLengthPrefixed,
+	})
+	addField := func(field *descriptor.FieldDescriptorProto, inherited features) {
+		ix.presence[field] = editionsPresence(field, inherited.presence)
+		encoding := featureValues(fieldOptions(field), fieldOptionsFeaturesNumber, inherited).encoding
+		ix.delimited[field] = encoding == messageEncodingDelimited && isMessage(field)
+	}
+	var addMessage func(msg *descriptor.DescriptorProto, inherited features)
+	addMessage = func(msg *descriptor.DescriptorProto, inherited features) {
+		msgFeatures := featureValues(messageOptions(msg), messageOptionsFeaturesNumber, inherited)
+		for _, fields := range [][]*descriptor.FieldDescriptorProto{msg.Field, msg.Extension} {
+			for _, field := range fields {
+				addField(field, msgFeatures)
+			}
+		}
+		for _, nested := range msg.NestedType {
+			addMessage(nested, msgFeatures)
+		}
+	}
+	for _, field := range file.Extension {
+		addField(field, fileFeatures)
+	}
+	for _, msg := range file.MessageType {
+		addMessage(msg, fileFeatures)
+	}
+}
+
+// features are the values of the features read here, as set on or
+// inherited by a definition.
+type features struct {
+	presence uint64
+	encoding uint64
+}
+
+// labelPresence returns the presence of field of a file of the given syntax,
+// "proto2" or "proto3".
+func labelPresence(field *descriptor.FieldDescriptorProto, syntax string) Presence {
+	switch {
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return Implicit
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		return Required
+	case syntax != "proto3", field.OneofIndex != nil, isMessage(field):
+		return Explicit
+	}
+	return Implicit
+}
+
+// editionsPresence returns the presence of field of an editions file, given
+// the field_presence feature inherited from its enclosing definitions.
+func editionsPresence(field *descriptor.FieldDescriptorProto, inherited uint64) Presence {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return Implicit
+	}
+	if field.OneofIndex != nil || field.Extendee != nil || isMessage(field) {
+		return Explicit
+	}
+	switch featureValues(fieldOptions(field), fieldOptionsFeaturesNumber, features{presence: inherited}).presence {
+	case fieldPresenceImplicit:
+		return Implicit
+	case fieldPresenceLegacyRequired:
+		return Required
+	}
+	return Explicit
+}
+
+// fileOptions, messageOptions and fieldOptions return the unknown fields of
+// the options of a definition, which hold their features.
+func fileOptions(file *descriptor.FileDescriptorProto) []byte {
+	if file.Options == nil {
+		return nil
+	}
+	return file.Options.XXX_unrecognized
+}
+
+func messageOptions(msg *descriptor.DescriptorProto) []byte {
+	if msg.Options == nil {
+		return nil
+	}
+	return msg.Options.XXX_unrecognized
+}
+
+func fieldOptions(field *descriptor.FieldDescriptorProto) []byte {
+	if field.Options == nil {
+		return nil
+	}
+	return field.Options.XXX_unrecognized
+}
+
+func isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE || field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP
+}
+
+// featureValues returns the features set in the features field, numbered
+// number, of the encoded options, with those not set there inherited.
+func featureValues(options []byte, number int32, inherited features) features {
+	set, ok := unknownBytes(options, number)
+	if !ok {
+		return inherited
+	}
+	if v, ok := unknownVarint(set, featureFieldPresenceNumber); ok && v != fieldPresenceUnknown {
+		inherited.presence = v
+	}
+	if v, ok := unknownVarint(set, featureMessageEncodingNumber); ok && v != messageEncodingUnknown {
+		inherited.encoding = v
+	}
+	return inherited
+}
+
+// Proto3Optional reports whether field was declared optional in a proto3
+// file. Such fields belong to a synthetic oneof of their own.
+func Proto3Optional(field *descriptor.FieldDescriptorProto) bool {
+	v, _ := unknownVarint(field.XXX_unrecognized, fieldProto3OptionalNumber)
+	return v != 0
+}
+
+// SyntheticOneof reports whether the oneof of msg at index was made up by
+// protoc for a proto3 optional field, rather than declared.
+func SyntheticOneof(msg *descriptor.DescriptorProto, index int32) bool {
+	for _, field := range msg.Field {
+		if field.OneofIndex != nil && field.GetOneofIndex() == index {
+			return Proto3Optional(field)
+		}
+	}
+	return false
+}
+
+// Editions reports whether file uses editions rather than proto2 or proto3.
+func Editions(file *descriptor.FileDescriptorProto) bool {
+	return file.GetSyntax() == "editions"
+}
+
+// Edition returns the edition of file, or 0 if it doesn't use editions.
+func Edition(file *descriptor.FileDescriptorProto) int32 {
+	v, _ := unknownVarint(file.XXX_unrecognized, fileEditionNumber)
+	return int32(v)
+}
+
+// unknownVarint returns the last value of the varint field number of the
+// encoded message b.
+func unknownVarint(b []byte, number int32) (uint64, bool) {
+	var value uint64
+	found := false
+	walkFields(b, func(n int32, wireType int, v uint64, _ []byte) {
+		if n == number && wireType == proto.WireVarint {
+			value, found = v, true
+		}
+	})
+	return value, found
+}
+
+// unknownBytes returns the values of the message field number of the encoded
+// message b, merged as protobuf merges repeated occurrences.
+func unknownBytes(b []byte, number int32) ([]byte, bool) {
+	var value []byte
+	found := false
+	walkFields(b, func(n int32, wireType int, _ uint64, v []byte) {
+		if n == number && wireType == proto.WireBytes {
+			value, found = append(value, v...), true
+		}
+	})
+	return value, found
+}
+
+// walkFields calls fn with each field of the encoded message b, until the
+// end of b or the first malformed field.
+func walkFields(b []byte, fn func(number int32, wireType int, v uint64, bytes []byte)) {
+	buf := proto.NewBuffer(b)
+	for {
+		key, err := buf.DecodeVarint()
+		if err != nil {
+			return
+		}
+		number, wireType := int32(key>>3), int(key&7)
+		switch wireType {
+		case proto.WireVarint:
+			v, err := buf.DecodeVarint()
+			if err != nil {
+				return
+			}
+			fn(number, wireType, v, nil)
+		case proto.WireFixed64:
+			v, err := buf.DecodeFixed64()
+			if err != nil {
+				return
+			}
+			fn(number, wireType, v, nil)
+		case proto.WireFixed32:
+			v, err := buf.DecodeFixed32()
+			if err != nil {
+				return
+			}
+			fn(number, wireType, v, nil)
+		case proto.WireBytes:
+			v, err := buf.DecodeRawBytes(false)
+			if err != nil {
+				return
+			}
+			fn(number, wireType, 0, v)
+		default:
+			// Groups aren't used by the fields read here, and can't be
+			// skipped without decoding them.
+			return
+		}
+	}
+}
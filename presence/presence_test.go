@@ -0,0 +1,256 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package presence
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+func TestProto2(t *testing.T) {
+	file := parseFile(t, `syntax = "proto2";
+package p2;
+message A {
+  optional string optional_field = 1;
+  required string required_field = 2;
+  repeated string repeated_field = 3;
+  optional A message_field = 4;
+  extensions 100 to 200;
+}
+extend A {
+  optional int32 extension_field = 100;
+}
+`)
+	checkPresence(t, file, map[string]Presence{
+		"optional_field":  Explicit,
+		"required_field":  Required,
+		"repeated_field":  Implicit,
+		"message_field":   Explicit,
+		"extension_field": Explicit,
+	})
+}
+
+func TestProto3(t *testing.T) {
+	file := parseFile(t, `syntax = "proto3";
+package p3;
+message A {
+  string implicit_field = 1;
+  optional string optional_field = 2;
+  oneof choice {
+    string oneof_field = 3;
+  }
+  A message_field = 4;
+  repeated A repeated_field = 5;
+}
+`)
+	checkPresence(t, file, map[string]Presence{
+		"implicit_field": Implicit,
+		"optional_field": Explicit,
+		"oneof_field":    Explicit,
+		"message_field":  Explicit,
+		"repeated_field": Implicit,
+	})
+
+	msg := file.MessageType[0]
+	if !Proto3Optional(msg.Field[1]) || Proto3Optional(msg.Field[0]) || Proto3Optional(msg.Field[2]) {
+		t.Error("optional_field should be the only proto3 optional field")
+	}
+	if SyntheticOneof(msg, msg.Field[2].GetOneofIndex()) || !SyntheticOneof(msg, msg.Field[1].GetOneofIndex()) {
+		t.Error("the oneof of optional_field should be the only synthetic oneof")
+	}
+}
+
+func TestEditions(t *testing.T) {
+	file := parseFile(t, `edition = "2023";
+package ed;
+message Default {
+  string explicit_default = 1;
+  string implicit_field = 2 [features.field_presence = IMPLICIT];
+  string required_field = 3 [features.field_presence = LEGACY_REQUIRED];
+  repeated string repeated_field = 4;
+}
+message Implicit {
+  option features.field_presence = IMPLICIT;
+  string implicit_inherited = 1;
+  string explicit_override = 2 [features.field_presence = EXPLICIT];
+  Default message_field = 3;
+  oneof choice {
+    string oneof_field = 4;
+  }
+  message Nested {
+    string implicit_nested = 1;
+  }
+}
+`)
+	if !Editions(file) || Edition(file) != Edition2023 {
+		t.Fatalf("got edition %d, want %d", Edition(file), Edition2023)
+	}
+	checkPresence(t, file, map[string]Presence{
+		"explicit_default":   Explicit,
+		"implicit_field":     Implicit,
+		"required_field":     Required,
+		"repeated_field":     Implicit,
+		"implicit_inherited": Implicit,
+		"explicit_override":  Explicit,
+		"message_field":      Explicit,
+		"oneof_field":        Explicit,
+		"implicit_nested":    Implicit,
+	})
+}
+
+func TestEditionsFileFeatures(t *testing.T) {
+	file := parseFile(t, `edition = "2023";
+package ed;
+option features.field_presence = IMPLICIT;
+message A {
+  string implicit_inherited = 1;
+  string explicit_override = 2 [features.field_presence = EXPLICIT];
+}
+`)
+	checkPresence(t, file, map[string]Presence{
+		"implicit_inherited": Implicit,
+		"explicit_override":  Explicit,
+	})
+}
+
+// TestFeaturesMerged checks that features set by several occurrences of the
+// features field are merged, the last value winning.
+func TestFeaturesMerged(t *testing.T) {
+	features := func(presence uint64) []byte {
+		b := proto.EncodeVarint(uint64(featureFieldPresenceNumber)<<3 | proto.WireVarint)
+		b = append(b, proto.EncodeVarint(presence)...)
+		buf := proto.NewBuffer(nil)
+		buf.EncodeVarint(uint64(fieldOptionsFeaturesNumber)<<3 | proto.WireBytes)
+		buf.EncodeRawBytes(b)
+		return buf.Bytes()
+	}
+	field := &descriptor.FieldDescriptorProto{
+		Name:    proto.String("field"),
+		Label:   descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:    descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+		Options: &descriptor.FieldOptions{XXX_unrecognized: append(features(fieldPresenceImplicit), features(fieldPresenceExplicit)...)},
+	}
+	if got := editionsPresence(field, fieldPresenceImplicit); got != Explicit {
+		t.Errorf("got presence %d, want Explicit", got)
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	proto2 := parseFile(t, `syntax = "proto2";
+package p2;
+message A {
+  optional group Result = 1 {
+    optional string url = 2;
+  }
+  optional A message_field = 3;
+}
+`)
+	editions := parseFile(t, `edition = "2023";
+package ed;
+option features.message_encoding = DELIMITED;
+message A {
+  A delimited_inherited = 1;
+  A length_prefixed = 2 [features.message_encoding = LENGTH_PREFIXED];
+  string scalar_field = 3;
+}
+message B {
+  A delimited_field = 1 [features.message_encoding = DELIMITED];
+}
+`)
+	ix := New([]*descriptor.FileDescriptorProto{proto2, editions})
+	for _, c := range []struct {
+		field *descriptor.FieldDescriptorProto
+		want  bool
+	}{
+		{proto2.MessageType[0].Field[0], true},
+		{proto2.MessageType[0].Field[1], false},
+		{editions.MessageType[0].Field[0], true},
+		{editions.MessageType[0].Field[1], false},
+		{editions.MessageType[0].Field[2], false},
+		{editions.MessageType[1].Field[0], true},
+	} {
+		if got := ix.Delimited(c.field); got != c.want {
+			t.Errorf("field %s: got delimited %v, want %v", c.field.GetName(), got, c.want)
+		}
+	}
+}
+
+func TestUnindexedFields(t *testing.T) {
+	field := &descriptor.FieldDescriptorProto{
+		Name:  proto.String("field"),
+		Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:  descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+	}
+	var nilIndex *Index
+	for _, ix := range []*Index{nilIndex, New(nil)} {
+		if got := ix.Of(field); got != Explicit {
+			t.Errorf("got presence %d, want fields outside the index to follow proto2", got)
+		}
+	}
+}
+
+// checkPresence checks the presence of the fields of file, by name.
+func checkPresence(t *testing.T, file *descriptor.FileDescriptorProto, want map[string]Presence) {
+	t.Helper()
+	ix := New([]*descriptor.FileDescriptorProto{file})
+	fields := map[string]*descriptor.FieldDescriptorProto{}
+	var addMessage func(msg *descriptor.DescriptorProto)
+	addMessage = func(msg *descriptor.DescriptorProto) {
+		for _, field := range append(append([]*descriptor.FieldDescriptorProto(nil), msg.Field...), msg.Extension...) {
+			fields[field.GetName()] = field
+		}
+		for _, nested := range msg.NestedType {
+			addMessage(nested)
+		}
+	}
+	for _, msg := range file.MessageType {
+		addMessage(msg)
+	}
+	for _, field := range file.Extension {
+		fields[field.GetName()] = field
+	}
+	for name, presence := range want {
+		field, ok := fields[name]
+		if !ok {
+			t.Errorf("no field %s", name)
+			continue
+		}
+		if got := ix.Of(field); got != presence {
+			t.Errorf("field %s: got presence %d, want %d", name, got, presence)
+		}
+	}
+}
+
+func parseFile(t *testing.T, src string) *descriptor.FileDescriptorProto {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "presence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.proto"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files[len(files)-1]
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/options"
+)
+
+const (
+	authNone   = "none"
+	authBearer = "bearer"
+	authAPIKey = "apikey"
+	authBasic  = "basic"
+	authOAuth2 = "oauth2"
+
+	defaultAPIKeyHeader = "X-API-Key"
+)
+
+// authVariables lists the environment variables holding the credentials of
+// each type of authentication.
+var authVariables = map[string][]string{
+	authBearer: {"auth_token"},
+	authAPIKey: {"api_key"},
+	authBasic:  {"auth_username", "auth_password"},
+	authOAuth2: {"oauth2_token_url", "oauth2_client_id", "oauth2_client_secret", "oauth2_scope"},
+}
+
+// authConfig describes how a request authenticates.
+type authConfig struct {
+	typ          string // One of the auth constants, or "" if not configured.
+	apiKeyHeader string // Header carrying the key of apikey authentication.
+}
+
+// checkAuthType returns an error if typ isn't a type of authentication
+// supported by protocol.
+func checkAuthType(typ string, protocol string) error {
+	switch typ {
+	case authNone, authBearer, authAPIKey:
+		return nil
+	case authBasic, authOAuth2:
+		if protocol == protocolGRPC {
+			return fmt.Errorf("auth %q is not supported by protocol %q", typ, protocol)
+		}
+		return nil
+	}
+	return fmt.Errorf("auth does not support %q", typ)
+}
+
+// methodAuth returns the authentication of method's request. The method's
+// option takes precedence over its service's, which takes precedence over the
+// auth parameter.
+func (e *insomniaenv) methodAuth(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) authConfig {
+	auth := authConfig{typ: e.params.auth, apiKeyHeader: e.params.apiKeyHeader}
+	for _, opt := range []*options.Auth{options.Service(service).Auth, options.Method(method).Auth} {
+		if opt == nil || opt.Type == "" {
+			continue
+		}
+		auth.typ = opt.Type
+		if opt.ApiKeyHeader != "" {
+			auth.apiKeyHeader = opt.ApiKeyHeader
+		}
+	}
+	if auth.apiKeyHeader == "" {
+		auth.apiKeyHeader = defaultAPIKeyHeader
+	}
+	return auth
+}
+
+// fileAuthVariables returns the environment variables holding the
+// credentials of every request generated for file, sorted by name.
+func (e *insomniaenv) fileAuthVariables(file *descriptor.FileDescriptorProto) []string {
+	seen := map[string]bool{}
+	variables := []string{}
+	for _, service := range file.Service {
+		for _, method := range service.Method {
+			if !e.methodIncluded(file, service, method) || !e.streamingSupported(method) {
+				continue
+			}
+			for _, variable := range authVariables[e.methodAuth(service, method).typ] {
+				if !seen[variable] {
+					seen[variable] = true
+					variables = append(variables, variable)
+				}
+			}
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// insomniaAuthentication returns the authentication block of an Insomnia
+// request, or nil for requests without authentication.
+func (auth authConfig) insomniaAuthentication() map[string]string {
+	switch auth.typ {
+	case authBearer:
+		return map[string]string{
+			"type":  "bearer",
+			"token": "{{ auth_token }}",
+		}
+	case authAPIKey:
+		return map[string]string{
+			"type":  "apikey",
+			"key":   auth.apiKeyHeader,
+			"value": "{{ api_key }}",
+			"addTo": "header",
+		}
+	case authBasic:
+		return map[string]string{
+			"type":     "basic",
+			"username": "{{ auth_username }}",
+			"password": "{{ auth_password }}",
+		}
+	case authOAuth2:
+		return map[string]string{
+			"type":           "oauth2",
+			"grantType":      "client_credentials",
+			"accessTokenUrl": "{{ oauth2_token_url }}",
+			"clientId":       "{{ oauth2_client_id }}",
+			"clientSecret":   "{{ oauth2_client_secret }}",
+			"scope":          "{{ oauth2_scope }}",
+		}
+	}
+	return nil
+}
+
+// grpcMetadata returns the metadata carrying the credentials of an Insomnia
+// gRPC request, which has no authentication block of its own.
+func (auth authConfig) grpcMetadata() []map[string]string {
+	switch auth.typ {
+	case authBearer:
+		return []map[string]string{nameValue("authorization", "Bearer {{ auth_token }}")}
+	case authAPIKey:
+		return []map[string]string{nameValue(strings.ToLower(auth.apiKeyHeader), "{{ api_key }}")}
+	}
+	return []map[string]string{}
+}
+
+// hoppscotchAuth returns the auth block of a Hoppscotch request. Requests
+// without configured authentication inherit their collection's.
+func (auth authConfig) hoppscotchAuth() HoppscotchAuth {
+	switch auth.typ {
+	case authNone:
+		return HoppscotchAuth{AuthType: "none", AuthActive: true}
+	case authBearer:
+		return HoppscotchAuth{AuthType: "bearer", AuthActive: true, Token: "<<auth_token>>"}
+	case authAPIKey:
+		return HoppscotchAuth{AuthType: "api-key", AuthActive: true, Key: auth.apiKeyHeader, Value: "<<api_key>>", AddTo: "Headers"}
+	case authBasic:
+		return HoppscotchAuth{AuthType: "basic", AuthActive: true, Username: "<<auth_username>>", Password: "<<auth_password>>"}
+	case authOAuth2:
+		// Hoppscotch doesn't support the client credentials grant, so the
+		// token is fetched by Hoppscotch's own OAuth 2 flow.
+		return HoppscotchAuth{AuthType: "oauth-2", AuthActive: true, AccessTokenURL: "<<oauth2_token_url>>", ClientID: "<<oauth2_client_id>>", Scope: "<<oauth2_scope>>"}
+	}
+	return HoppscotchAuth{AuthType: "inherit", AuthActive: true}
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+const usage = `Usage:
+  protoc-gen-insomniaenv
+        Run as a protoc plugin, reading a CodeGeneratorRequest from stdin.
+  protoc-gen-insomniaenv gen [flags] <file.proto>...
+        Generate from .proto files, without protoc. --out is the output
+        directory, or the file to write the export to if it ends in .json.
+  protoc-gen-insomniaenv gen --descriptor-set <file> [flags]
+        Generate from a FileDescriptorSet, without protoc.
+  protoc-gen-insomniaenv diff --old <file> [flags] (--new <file> | <file.proto>...)
+        Report the services, methods and inputs which changed since an old
+        descriptor set or Insomnia export.
+
+Run "protoc-gen-insomniaenv <command> -h" for the flags of a command.
+`
+
+// runCommand runs the standalone command named by the first of args, with
+// the remaining args as its flags.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "gen":
+		return runGen(args[1:])
+	case "diff":
+		return runDiff(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// stringList is a flag which can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// runGen generates the same files protoc would, either from .proto files it
+// parses itself or from a serialized FileDescriptorSet, as written by
+// protoc -o or buf build, and writes them under an output directory.
+func runGen(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	descriptorSet := flags.String("descriptor-set", "", "FileDescriptorSet to generate from, written by protoc --include_imports -o or buf build, instead of .proto files.")
+	out := flags.String("out", ".", "Directory the generated files are written to, or, when it ends in .json, the file the only generated JSON file is written to, with the other generated files written to its directory.")
+	params := flags.String("params", "", "Comma-separated key=value parameters, as passed to the plugin by protoc.")
+	var files, importPaths stringList
+	flags.Var(&files, "file", "Proto file to generate, by its name in the descriptor set. Can be repeated. Defaults to every file in the set.")
+	flags.Var(&importPaths, "I", "Directory imports are looked up in, like protoc's -I. Can be repeated. Defaults to the current directory. The well-known types and insomnia/options.proto are built in.")
+	flags.Var(&importPaths, "proto_path", "Same as -I.")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var req *plugin.CodeGeneratorRequest
+	var err error
+	switch {
+	case *descriptorSet != "" && flags.NArg() > 0:
+		return errors.New("gen: use either --descriptor-set or .proto files, not both")
+	case *descriptorSet != "":
+		req, err = readDescriptorSet(*descriptorSet, files, *params)
+	case flags.NArg() > 0:
+		req, err = parseProtoFiles(flags.Args(), importPaths, *params)
+	default:
+		return errors.New("gen: .proto files or --descriptor-set are required")
+	}
+	if err != nil {
+		return err
+	}
+
+	t := insomniaenv{}
+	resp, err := t.Generate(req)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(*out, ".json") {
+		return writeResponseFile(*out, resp)
+	}
+	return writeResponseFiles(*out, resp)
+}
+
+// readDescriptorSet returns the request generating files from the
+// FileDescriptorSet in the file at path.
+func readDescriptorSet(path string, files []string, parameter string) (*plugin.CodeGeneratorRequest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("%s is not a FileDescriptorSet: %v", path, err)
+	}
+	return newCodeGeneratorRequest(set.File, files, parameter)
+}
+
+// parseProtoFiles returns the request generating the .proto files at paths,
+// which are named relative to the import path they're in, like protoc does.
+// Paths which don't exist are taken as names within the import paths.
+func parseProtoFiles(paths, importPaths []string, parameter string) (*plugin.CodeGeneratorRequest, error) {
+	parser := protoparse.Parser{ImportPaths: importPaths}
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = filepath.ToSlash(path)
+		if _, err := os.Stat(path); err == nil {
+			if names[i], err = parser.ImportName(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	files, err := parser.ParseFiles(names...)
+	if err != nil {
+		return nil, err
+	}
+	return newCodeGeneratorRequest(files, names, parameter)
+}
+
+// newCodeGeneratorRequest returns the request protoc would send to generate
+// filesToGenerate from files, or every file if filesToGenerate is empty.
+// Like protoc, it requires files to list each file after its dependencies.
+func newCodeGeneratorRequest(files []*descriptor.FileDescriptorProto, filesToGenerate []string, parameter string) (*plugin.CodeGeneratorRequest, error) {
+	seen := map[string]bool{}
+	for _, file := range files {
+		for _, dep := range file.Dependency {
+			if !seen[dep] {
+				return nil, fmt.Errorf("%s imports %s, which is missing from the descriptor set: build it with --include_imports", file.GetName(), dep)
+			}
+		}
+		seen[file.GetName()] = true
+	}
+
+	if len(filesToGenerate) == 0 {
+		for _, file := range files {
+			filesToGenerate = append(filesToGenerate, file.GetName())
+		}
+	}
+	for _, name := range filesToGenerate {
+		if !seen[name] {
+			return nil, fmt.Errorf("%s is not in the descriptor set", name)
+		}
+	}
+
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: filesToGenerate,
+		ProtoFile:      files,
+	}
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+	return req, nil
+}
+
+// writeResponseFile writes the only JSON file of resp to path, and the other
+// files of resp under the directory of path, where the files the JSON file
+// refers to are found.
+func writeResponseFile(path string, resp *plugin.CodeGeneratorResponse) error {
+	if resp.Error != nil {
+		return errors.New(resp.GetError())
+	}
+	var jsonFile *plugin.CodeGeneratorResponse_File
+	var others []*plugin.CodeGeneratorResponse_File
+	var jsonNames []string
+	for _, file := range resp.File {
+		if strings.HasSuffix(file.GetName(), ".json") {
+			jsonFile = file
+			jsonNames = append(jsonNames, file.GetName())
+		} else {
+			others = append(others, file)
+		}
+	}
+	if len(jsonNames) != 1 {
+		return fmt.Errorf("gen: --out %s names a file, but %d JSON files were generated (%s): give a directory instead", path, len(jsonNames), strings.Join(jsonNames, ", "))
+	}
+	if err := writeResponseFiles(filepath.Dir(path), &plugin.CodeGeneratorResponse{File: others}); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(jsonFile.GetContent()), 0644)
+}
+
+// writeResponseFiles writes the files of resp under dir, creating any
+// directories their names include.
+func writeResponseFiles(dir string, resp *plugin.CodeGeneratorResponse) error {
+	if resp.Error != nil {
+		return errors.New(resp.GetError())
+	}
+	for _, file := range resp.File {
+		path := filepath.Join(dir, filepath.FromSlash(file.GetName()))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(file.GetContent()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+var importFlags = []string{"-I", filepath.Join("testdata", "protos"), "-I", filepath.Join("..", "proto")}
+
+// TestGen checks that the gen command writes the plugin's golden files, both
+// from .proto files and from a descriptor set.
+func TestGen(t *testing.T) {
+	for _, name := range []string{"connect", "protobuf_body", "streaming_connect"} {
+		files, parameter := goldenCase(t, name)
+		t.Run(name, func(t *testing.T) {
+			out := tempDir(t)
+			defer os.RemoveAll(out)
+			args := append(append([]string{"--out", out, "--params", parameter}, importFlags...), files...)
+			runGenOrFail(t, args)
+			compareGolden(t, out, filepath.Join("testdata", "golden", name))
+		})
+		t.Run(name+"_descriptor_set", func(t *testing.T) {
+			out := tempDir(t)
+			defer os.RemoveAll(out)
+			set := writeDescriptorSet(t, out, files)
+			args := []string{"--descriptor-set", set, "--out", out, "--params", parameter}
+			for _, file := range files {
+				args = append(args, "--file", file)
+			}
+			runGenOrFail(t, args)
+			if err := os.Remove(set); err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, out, filepath.Join("testdata", "golden", name))
+		})
+	}
+}
+
+// TestGenBuiltinOptions checks that files using the plugin's options are
+// generated without its proto directory on the import path.
+func TestGenBuiltinOptions(t *testing.T) {
+	out := tempDir(t)
+	defer os.RemoveAll(out)
+	files, parameter := goldenCase(t, "connect")
+	args := append([]string{"--out", out, "--params", parameter, "-I", filepath.Join("testdata", "protos")}, files...)
+	runGenOrFail(t, args)
+	compareGolden(t, out, filepath.Join("testdata", "golden", "connect"))
+}
+
+// TestGenOutFile checks that --out names the file the export is written to
+// when it ends in .json, with the body files the export refers to written to
+// its directory.
+func TestGenOutFile(t *testing.T) {
+	out := tempDir(t)
+	defer os.RemoveAll(out)
+	files, parameter := goldenCase(t, "streaming_connect")
+	runGenOrFail(t, append(append([]string{"--out", filepath.Join(out, "api", "workspace.json"), "--params", parameter}, importFlags...), files...))
+
+	golden := filepath.Join("testdata", "golden", "streaming_connect")
+	for _, file := range []struct{ got, want string }{
+		{"api/workspace.json", "streaming-insomnia-env.json"},
+		{"api/streaming-streams/Events/Upload.bin", "streaming-streams/Events/Upload.bin"},
+		{"api/streaming-routes.txt", "streaming-routes.txt"},
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(file.got)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		want, err := ioutil.ReadFile(filepath.Join(golden, filepath.FromSlash(file.want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s differs from %s", file.got, file.want)
+		}
+	}
+
+	err := runGen(append(append([]string{"--out", filepath.Join(out, "workspace.json")}, importFlags...), "scalars.proto", "services.proto"))
+	if err == nil || !strings.Contains(err.Error(), "2 JSON files were generated") {
+		t.Errorf("got error %v, want it to report the 2 JSON files generated", err)
+	}
+}
+
+// goldenCase returns the files and parameter of the golden case named name.
+func goldenCase(t *testing.T, name string) (files []string, parameter string) {
+	t.Helper()
+	for _, c := range goldenCases {
+		if c.name == name {
+			return c.files, c.parameter
+		}
+	}
+	t.Fatalf("no golden case %q", name)
+	return nil, ""
+}
+
+func runGenOrFail(t *testing.T, args []string) {
+	t.Helper()
+	rand.Seed(1)
+	if err := runGen(args); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeDescriptorSet writes the descriptor set of files and their imports
+// to dir, as protoc --include_imports --include_source_info -o would, and
+// returns its path.
+func writeDescriptorSet(t *testing.T, dir string, files []string) string {
+	t.Helper()
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "protos"), filepath.Join("..", "proto")}}
+	protoFiles, err := parser.ParseFiles(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := proto.Marshal(&descriptor.FileDescriptorSet{File: protoFiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "api.pb")
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// compareGolden checks that dir holds the same files as the golden
+// directory.
+func compareGolden(t *testing.T, dir, golden string) {
+	t.Helper()
+	want := readTree(t, golden)
+	got := readTree(t, dir)
+	for name, content := range want {
+		if got[name] == nil {
+			t.Errorf("%s wasn't generated", name)
+		} else if string(got[name]) != string(content) {
+			t.Errorf("%s differs from its golden file\n%s", name, firstDifference(string(content), string(got[name])))
+		}
+	}
+	for name := range got {
+		if want[name] == nil {
+			t.Errorf("%s was generated, but has no golden file", name)
+		}
+	}
+}
+
+// readTree returns the contents of the files under dir, by slash-separated
+// path.
+func readTree(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = b
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
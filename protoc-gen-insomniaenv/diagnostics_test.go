@@ -0,0 +1,187 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+const legacyDiagnostics = `legacy.proto:10:3: error: method fixtures.diagnostics.Legacy.Store: request can't be generated: field count: strconv.ParseInt: parsing "many": invalid syntax
+legacy.proto:11:3: warning: method fixtures.diagnostics.Legacy.Sync: bidirectional streaming methods can't be called with protocol "grpc-web" in format "insomnia", so it has no request
+1 error, 1 warning`
+
+func TestDiagnosticsSummary(t *testing.T) {
+	var stderr bytes.Buffer
+	resp := generateDiagnostics(t, "protocol=grpc-web", &stderr)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.GetError())
+	}
+	if len(resp.File) != 1 {
+		t.Errorf("generated %d files, want 1", len(resp.File))
+	}
+	if got := strings.TrimSpace(stderr.String()); got != legacyDiagnostics {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, legacyDiagnostics)
+	}
+}
+
+func TestDiagnosticsStrict(t *testing.T) {
+	var stderr bytes.Buffer
+	resp := generateDiagnostics(t, "protocol=grpc-web,strict=true", &stderr)
+	if resp.GetError() != legacyDiagnostics {
+		t.Errorf("got error:\n%s\nwant:\n%s", resp.GetError(), legacyDiagnostics)
+	}
+	if len(resp.File) != 0 {
+		t.Errorf("generated %d files in strict mode", len(resp.File))
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("unexpected output in strict mode:\n%s", stderr.String())
+	}
+}
+
+func TestDiagnosticsMissingImport(t *testing.T) {
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "protos")}}
+	files, err := parser.ParseFiles("imports.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave out the imported file, as a descriptor set built without
+	// --include_imports would.
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"imports.proto"},
+		Parameter:      proto.String("strict=true"),
+	}
+	for _, file := range files {
+		if file.GetName() != "common/types.proto" {
+			req.ProtoFile = append(req.ProtoFile, file)
+		}
+	}
+
+	e := insomniaenv{}
+	resp, err := e.Generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"imports.proto:15:3: warning: field fixtures.imports.Invoice.total: message .fixtures.common.Money could not be found, so it's mocked with a placeholder",
+		"imports.proto:16:3: warning: field fixtures.imports.Invoice.visibility: enum .fixtures.common.Visibility could not be found, so it's mocked with a placeholder",
+	} {
+		if !strings.Contains(resp.GetError(), want) {
+			t.Errorf("got error:\n%s\nwant it to contain:\n%s", resp.GetError(), want)
+		}
+	}
+}
+
+func TestDiagnosticsStreaming(t *testing.T) {
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "protos"), filepath.Join("..", "proto")}}
+	files, err := parser.ParseFiles("streaming.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := newCodeGeneratorRequest(files, []string{"streaming.proto"}, "routes=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	e := insomniaenv{stderr: &stderr}
+	resp, err := e.Generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `streaming.proto:12:3: warning: method fixtures.streaming.Events.Watch: server streaming methods can't be called with protocol "twirp" in format "insomnia", so it has no request
+streaming.proto:14:3: warning: method fixtures.streaming.Events.Upload: client streaming methods can't be called with protocol "twirp" in format "insomnia", so it has no request
+streaming.proto:21:3: warning: method fixtures.streaming.Events.Chat: bidirectional streaming methods can't be called with protocol "twirp" in format "insomnia", so it has no request
+0 errors, 3 warnings`
+	if got := strings.TrimSpace(stderr.String()); got != want {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, want)
+	}
+	// Only the unary method gets a request.
+	for _, file := range resp.File {
+		if file.GetName() != "streaming-routes.txt" {
+			continue
+		}
+		if got, want := file.GetContent(), "POST /twirp/fixtures.streaming.Events/GetEvent\n"; got != want {
+			t.Errorf("got routes:\n%s\nwant:\n%s", got, want)
+		}
+		return
+	}
+	t.Error("streaming-routes.txt wasn't generated")
+}
+
+// generateDiagnostics generates testdata/diagnostics/legacy.proto with
+// parameter, writing diagnostics to stderr.
+func generateDiagnostics(t *testing.T, parameter string, stderr *bytes.Buffer) *plugin.CodeGeneratorResponse {
+	t.Helper()
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "diagnostics"), filepath.Join("..", "proto")}}
+	files, err := parser.ParseFiles("legacy.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := newCodeGeneratorRequest(files, []string{"legacy.proto"}, parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := insomniaenv{stderr: stderr}
+	resp, err := e.Generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// TestDiagnosticsHoppscotchTemplates checks that header values with Insomnia
+// template tags Hoppscotch can't evaluate are reported and kept as they are,
+// while variables are translated.
+func TestDiagnosticsHoppscotchTemplates(t *testing.T) {
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "protos")}}
+	files, err := parser.ParseFiles("proto2.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := newCodeGeneratorRequest(files, []string{"proto2.proto"}, "format=hoppscotch,headers="+filepath.Join("testdata", "headers", "untranslatable.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	e := insomniaenv{stderr: &stderr}
+	resp, err := e.Generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `proto2.proto:8:3: warning: method fixtures.proto2.Inventory.AddItem: header X-Request-Id has Insomnia template tags Hoppscotch can't evaluate, so they're sent as is: {% uuid 'v4' %}
+0 errors, 1 warning`
+	if got := strings.TrimSpace(stderr.String()); got != want {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", got, want)
+	}
+	for _, file := range resp.File {
+		if file.GetName() != "proto2-hoppscotch-collection.json" {
+			continue
+		}
+		for _, header := range []string{`"value": "{% uuid 'v4' %}"`, `"value": "<<session_id>>"`} {
+			if !strings.Contains(file.GetContent(), header) {
+				t.Errorf("collection has no header with %s", header)
+			}
+		}
+		return
+	}
+	t.Error("proto2-hoppscotch-collection.json wasn't generated")
+}
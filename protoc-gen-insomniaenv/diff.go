@@ -0,0 +1,507 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/insomnia"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+	"github.com/twitchtv/protogen"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// apiServices maps the fully-qualified names of services to their methods,
+// by name. It's what the diff command compares, and what exports generated
+// by the plugin record of their file's API.
+type apiServices map[string]map[string]*apiMethod
+
+type apiMethod struct {
+	InputType  string     `json:"inputType"`
+	OutputType string     `json:"outputType"`
+	Streaming  string     `json:"streaming"`
+	Fields     []apiField `json:"fields"` // Fields of the input type.
+}
+
+type apiField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+func (f apiField) String() string {
+	return fmt.Sprintf("%s %s", f.Type, f.Label)
+}
+
+// generatedServicesKey is the field of the workspaces of generated exports
+// holding the API of their file.
+const generatedServicesKey = "generatedServices"
+
+// generatedWorkspace is a workspace recording the API of its file, which
+// the diff command reads from exports instead of their requests.
+type generatedWorkspace struct {
+	insomnia.Workspace
+	Services apiServices `json:"generatedServices"`
+}
+
+type apiRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type apiFieldChange struct {
+	Old apiField `json:"old"`
+	New apiField `json:"new"`
+}
+
+type apiMethodDiff struct {
+	Method        string           `json:"method"`
+	InputType     *apiRename       `json:"inputType,omitempty"`
+	OutputType    *apiRename       `json:"outputType,omitempty"`
+	Streaming     *apiRename       `json:"streaming,omitempty"`
+	AddedFields   []apiField       `json:"addedFields,omitempty"`
+	RemovedFields []apiField       `json:"removedFields,omitempty"`
+	ChangedFields []apiFieldChange `json:"changedFields,omitempty"`
+}
+
+// apiDiff lists the changes between two versions of an API. Methods are
+// named <package>.<Service>/<Method>.
+type apiDiff struct {
+	AddedServices   []string        `json:"addedServices"`
+	RemovedServices []string        `json:"removedServices"`
+	RenamedServices []apiRename     `json:"renamedServices"`
+	AddedMethods    []string        `json:"addedMethods"`
+	RemovedMethods  []string        `json:"removedMethods"`
+	RenamedMethods  []apiRename     `json:"renamedMethods"`
+	ChangedMethods  []apiMethodDiff `json:"changedMethods"`
+}
+
+// runDiff reports the changes between an old version of an API, read from a
+// descriptor set or an Insomnia export, and a new version, read from a
+// descriptor set or .proto files.
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	old := flags.String("old", "", "Old version of the API: a FileDescriptorSet, or an Insomnia export generated by the plugin.")
+	newSet := flags.String("new", "", "New version of the API as a FileDescriptorSet, instead of .proto files.")
+	jsonOutput := flags.Bool("json", false, "Report the changes as JSON.")
+	var importPaths stringList
+	flags.Var(&importPaths, "I", "Directory imports are looked up in, like protoc's -I. Can be repeated. Defaults to the current directory.")
+	flags.Var(&importPaths, "proto_path", "Same as -I.")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if *old == "" {
+		return errors.New("diff: --old is required")
+	}
+
+	b, err := ioutil.ReadFile(*old)
+	if err != nil {
+		return err
+	}
+	var oldServices apiServices
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		oldServices, err = exportServices(b)
+	} else {
+		var req *plugin.CodeGeneratorRequest
+		if req, err = readDescriptorSet(*old, nil, ""); err == nil {
+			oldServices, err = descriptorServices(req)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var req *plugin.CodeGeneratorRequest
+	switch {
+	case *newSet != "" && flags.NArg() > 0:
+		return errors.New("diff: use either --new or .proto files, not both")
+	case *newSet != "":
+		req, err = readDescriptorSet(*newSet, nil, "")
+	case flags.NArg() > 0:
+		req, err = parseProtoFiles(flags.Args(), importPaths, "")
+	default:
+		return errors.New("diff: .proto files or --new are required")
+	}
+	if err != nil {
+		return err
+	}
+	newServices, err := descriptorServices(req)
+	if err != nil {
+		return err
+	}
+
+	d := diffServices(oldServices, newServices)
+	if *jsonOutput {
+		out, err := json.MarshalIndent(d, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	writeDiff(os.Stdout, d)
+	return nil
+}
+
+// descriptorServices returns the services of the files req generates, with
+// all their methods. Parameters and filters don't apply: the diff is about
+// the API, not about which of its requests were generated.
+func descriptorServices(req *plugin.CodeGeneratorRequest) (apiServices, error) {
+	files, err := protogen.FilesToGenerate(req)
+	if err != nil {
+		return nil, err
+	}
+	registry := typemap.New(req.ProtoFile)
+	ix := presence.New(req.ProtoFile)
+	services := apiServices{}
+	for _, file := range files {
+		for name, methods := range fileServices(registry, ix, file) {
+			services[name] = methods
+		}
+	}
+	return services, nil
+}
+
+// fileServices returns the services of file with all their methods.
+func fileServices(registry *typemap.Registry, ix *presence.Index, file *descriptor.FileDescriptorProto) apiServices {
+	services := apiServices{}
+	for _, service := range file.Service {
+		methods := map[string]*apiMethod{}
+		for _, method := range service.Method {
+			m := &apiMethod{
+				InputType:  strings.TrimPrefix(method.GetInputType(), "."),
+				OutputType: strings.TrimPrefix(method.GetOutputType(), "."),
+				Streaming:  streamingKind(method),
+				Fields:     []apiField{},
+			}
+			if msg := registry.MessageDefinition(method.GetInputType()); msg != nil {
+				for _, field := range msg.Descriptor.Field {
+					name, typ, label := fieldColumns(ix, field)
+					m.Fields = append(m.Fields, apiField{Name: name, Type: typ, Label: label})
+				}
+			}
+			methods[method.GetName()] = m
+		}
+		services[protoServiceName(file, service)] = methods
+	}
+	return services
+}
+
+// exportServices returns the services recorded by the workspaces of an
+// Insomnia export generated by the plugin.
+func exportServices(b []byte) (apiServices, error) {
+	var export struct {
+		Resources []map[string]json.RawMessage `json:"resources"`
+	}
+	if err := json.Unmarshal(b, &export); err != nil {
+		return nil, fmt.Errorf("invalid Insomnia export: %v", err)
+	}
+
+	var services apiServices
+	for _, r := range export.Resources {
+		raw, ok := r[generatedServicesKey]
+		if !ok {
+			continue
+		}
+		var workspaceServices apiServices
+		if err := json.Unmarshal(raw, &workspaceServices); err != nil {
+			return nil, fmt.Errorf("invalid Insomnia export: %s: %v", generatedServicesKey, err)
+		}
+		if services == nil {
+			services = apiServices{}
+		}
+		for name, methods := range workspaceServices {
+			services[name] = methods
+		}
+	}
+	if services == nil {
+		return nil, fmt.Errorf("the Insomnia export doesn't record its API in a %s field: generate it again with this version of the plugin", generatedServicesKey)
+	}
+	return services, nil
+}
+
+// diffServices compares two versions of an API. A removed service and an
+// added service with the same method names are taken to be a renamed
+// service, as are a removed method and an added method of the same service
+// with the same input and output types, when there's no other candidate.
+func diffServices(old, new apiServices) *apiDiff {
+	d := &apiDiff{
+		AddedServices:   []string{},
+		RemovedServices: []string{},
+		RenamedServices: []apiRename{},
+		AddedMethods:    []string{},
+		RemovedMethods:  []string{},
+		RenamedMethods:  []apiRename{},
+		ChangedMethods:  []apiMethodDiff{},
+	}
+
+	removed, added, kept := diffNames(old.names(), new.names())
+	var pairs []apiRename
+	for _, name := range kept {
+		pairs = append(pairs, apiRename{From: name, To: name})
+	}
+	renames := matchRenames(removed, added, func(from, to string) bool {
+		return len(old[from]) > 0 && sameMethodNames(old[from], new[to])
+	})
+	for _, rename := range renames {
+		d.RenamedServices = append(d.RenamedServices, rename)
+		pairs = append(pairs, rename)
+		removed = removeName(removed, rename.From)
+		added = removeName(added, rename.To)
+	}
+	d.RemovedServices = append(d.RemovedServices, removed...)
+	d.AddedServices = append(d.AddedServices, added...)
+	for _, service := range removed {
+		for _, method := range sortedNames(old[service]) {
+			d.RemovedMethods = append(d.RemovedMethods, service+"/"+method)
+		}
+	}
+	for _, service := range added {
+		for _, method := range sortedNames(new[service]) {
+			d.AddedMethods = append(d.AddedMethods, service+"/"+method)
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].To < pairs[j].To })
+	for _, pair := range pairs {
+		diffMethods(d, pair, old[pair.From], new[pair.To])
+	}
+	return d
+}
+
+// diffMethods adds the changes between the methods of the old and new
+// versions of a service to d.
+func diffMethods(d *apiDiff, service apiRename, old, new map[string]*apiMethod) {
+	removed, added, kept := diffNames(sortedNames(old), sortedNames(new))
+	pairs := []apiRename{}
+	for _, name := range kept {
+		pairs = append(pairs, apiRename{From: name, To: name})
+	}
+	renames := matchRenames(removed, added, func(from, to string) bool {
+		return old[from].InputType != "" && old[from].InputType == new[to].InputType && old[from].OutputType == new[to].OutputType && old[from].Streaming == new[to].Streaming
+	})
+	for _, rename := range renames {
+		d.RenamedMethods = append(d.RenamedMethods, apiRename{From: service.From + "/" + rename.From, To: service.To + "/" + rename.To})
+		pairs = append(pairs, rename)
+		removed = removeName(removed, rename.From)
+		added = removeName(added, rename.To)
+	}
+	for _, name := range removed {
+		d.RemovedMethods = append(d.RemovedMethods, service.From+"/"+name)
+	}
+	for _, name := range added {
+		d.AddedMethods = append(d.AddedMethods, service.To+"/"+name)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].To < pairs[j].To })
+	for _, pair := range pairs {
+		if change := diffMethod(service.To+"/"+pair.To, old[pair.From], new[pair.To]); change != nil {
+			d.ChangedMethods = append(d.ChangedMethods, *change)
+		}
+	}
+}
+
+// diffMethod returns the changes to the types, streaming and input fields of
+// a method, or nil if there are none.
+func diffMethod(name string, old, new *apiMethod) *apiMethodDiff {
+	change := &apiMethodDiff{Method: name}
+	if old.InputType != new.InputType {
+		change.InputType = &apiRename{From: old.InputType, To: new.InputType}
+	}
+	if old.OutputType != new.OutputType {
+		change.OutputType = &apiRename{From: old.OutputType, To: new.OutputType}
+	}
+	if old.Streaming != new.Streaming {
+		change.Streaming = &apiRename{From: old.Streaming, To: new.Streaming}
+	}
+	oldFields := map[string]apiField{}
+	for _, field := range old.Fields {
+		oldFields[field.Name] = field
+	}
+	newFields := map[string]bool{}
+	for _, field := range new.Fields {
+		newFields[field.Name] = true
+		oldField, ok := oldFields[field.Name]
+		switch {
+		case !ok:
+			change.AddedFields = append(change.AddedFields, field)
+		case oldField != field:
+			change.ChangedFields = append(change.ChangedFields, apiFieldChange{Old: oldField, New: field})
+		}
+	}
+	for _, field := range old.Fields {
+		if !newFields[field.Name] {
+			change.RemovedFields = append(change.RemovedFields, field)
+		}
+	}
+	if change.InputType == nil && change.OutputType == nil && change.Streaming == nil && len(change.AddedFields) == 0 && len(change.RemovedFields) == 0 && len(change.ChangedFields) == 0 {
+		return nil
+	}
+	return change
+}
+
+// diffNames returns the names of oldNames which aren't in newNames, the
+// names of newNames which aren't in oldNames, and the names in both.
+func diffNames(oldNames, newNames []string) (removed, added, kept []string) {
+	inNew := map[string]bool{}
+	for _, name := range newNames {
+		inNew[name] = true
+	}
+	inOld := map[string]bool{}
+	for _, name := range oldNames {
+		inOld[name] = true
+		if inNew[name] {
+			kept = append(kept, name)
+		} else {
+			removed = append(removed, name)
+		}
+	}
+	for _, name := range newNames {
+		if !inOld[name] {
+			added = append(added, name)
+		}
+	}
+	return removed, added, kept
+}
+
+func (s apiServices) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedNames(methods map[string]*apiMethod) []string {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchRenames pairs each removed name with the only added name it matches,
+// provided that added name matches no other removed name.
+func matchRenames(removed, added []string, matches func(from, to string) bool) []apiRename {
+	candidates := map[string][]string{}
+	matchedBy := map[string]int{}
+	for _, from := range removed {
+		for _, to := range added {
+			if matches(from, to) {
+				candidates[from] = append(candidates[from], to)
+				matchedBy[to]++
+			}
+		}
+	}
+	var renames []apiRename
+	for _, from := range removed {
+		if c := candidates[from]; len(c) == 1 && matchedBy[c[0]] == 1 {
+			renames = append(renames, apiRename{From: from, To: c[0]})
+		}
+	}
+	return renames
+}
+
+func sameMethodNames(a, b map[string]*apiMethod) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func removeName(names []string, name string) []string {
+	for i, n := range names {
+		if n == name {
+			return append(names[:i:i], names[i+1:]...)
+		}
+	}
+	return names
+}
+
+// writeDiff writes a human-readable report of d.
+func writeDiff(w io.Writer, d *apiDiff) {
+	empty := true
+	list := func(title, marker string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		empty = false
+		fmt.Fprintf(w, "%s:\n", title)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s %s\n", marker, name)
+		}
+	}
+	renames := func(title string, renames []apiRename) {
+		var names []string
+		for _, rename := range renames {
+			names = append(names, rename.From+" -> "+rename.To)
+		}
+		list(title, "~", names)
+	}
+
+	list("Added services", "+", d.AddedServices)
+	list("Removed services", "-", d.RemovedServices)
+	renames("Renamed services", d.RenamedServices)
+	list("Added methods", "+", d.AddedMethods)
+	list("Removed methods", "-", d.RemovedMethods)
+	renames("Renamed methods", d.RenamedMethods)
+	if len(d.ChangedMethods) > 0 {
+		empty = false
+		fmt.Fprintln(w, "Changed methods:")
+		for _, change := range d.ChangedMethods {
+			fmt.Fprintf(w, "  %s\n", change.Method)
+			if change.InputType != nil {
+				fmt.Fprintf(w, "    input type: %s -> %s\n", change.InputType.From, change.InputType.To)
+			}
+			if change.OutputType != nil {
+				fmt.Fprintf(w, "    output type: %s -> %s\n", change.OutputType.From, change.OutputType.To)
+			}
+			if change.Streaming != nil {
+				fmt.Fprintf(w, "    streaming: %s -> %s\n", change.Streaming.From, change.Streaming.To)
+			}
+			for _, field := range change.AddedFields {
+				fmt.Fprintf(w, "    + %s (%s)\n", field.Name, field)
+			}
+			for _, field := range change.RemovedFields {
+				fmt.Fprintf(w, "    - %s (%s)\n", field.Name, field)
+			}
+			for _, field := range change.ChangedFields {
+				fmt.Fprintf(w, "    ~ %s: %s -> %s\n", field.New.Name, field.Old, field.New)
+			}
+		}
+	}
+	if empty {
+		fmt.Fprintln(w, "No changes.")
+	}
+}
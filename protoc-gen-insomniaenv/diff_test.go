@@ -0,0 +1,254 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const diffHeader = "syntax = \"proto3\";\npackage diff;\n"
+
+const diffMessages = `
+message GetRequest { string id = 1; }
+message ListRequest { int32 page_size = 1; }
+message Item { string id = 1; }
+`
+
+func TestDiffServices(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		old, new string
+		want     func(d *apiDiff)
+	}{
+		{
+			name: "unchanged",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			want: func(d *apiDiff) {},
+		},
+		{
+			name: "added method",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (Item); rpc List(ListRequest) returns (Item); }",
+			want: func(d *apiDiff) { d.AddedMethods = []string{"diff.Items/List"} },
+		},
+		{
+			name: "removed method",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); rpc List(ListRequest) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			want: func(d *apiDiff) { d.RemovedMethods = []string{"diff.Items/List"} },
+		},
+		{
+			name: "renamed method",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Fetch(GetRequest) returns (Item); }",
+			want: func(d *apiDiff) {
+				d.RenamedMethods = []apiRename{{From: "diff.Items/Get", To: "diff.Items/Fetch"}}
+			},
+		},
+		{
+			name: "added service",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (Item); } service Lists { rpc List(ListRequest) returns (Item); }",
+			want: func(d *apiDiff) {
+				d.AddedServices = []string{"diff.Lists"}
+				d.AddedMethods = []string{"diff.Lists/List"}
+			},
+		},
+		{
+			name: "changed types",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Get(ListRequest) returns (GetRequest); }",
+			want: func(d *apiDiff) {
+				d.ChangedMethods = []apiMethodDiff{{
+					Method:        "diff.Items/Get",
+					InputType:     &apiRename{From: "diff.GetRequest", To: "diff.ListRequest"},
+					OutputType:    &apiRename{From: "diff.Item", To: "diff.GetRequest"},
+					AddedFields:   []apiField{{Name: "pageSize", Type: "int32", Label: "implicit"}},
+					RemovedFields: []apiField{{Name: "id", Type: "string", Label: "implicit"}},
+				}}
+			},
+		},
+		{
+			name: "changed fields",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new: `service Items { rpc Get(GetRequest2) returns (Item); }
+message GetRequest2 { int64 id = 1; repeated string fields = 2; }`,
+			want: func(d *apiDiff) {
+				d.ChangedMethods = []apiMethodDiff{{
+					Method:      "diff.Items/Get",
+					InputType:   &apiRename{From: "diff.GetRequest", To: "diff.GetRequest2"},
+					AddedFields: []apiField{{Name: "fields", Type: "string", Label: "repeated"}},
+					ChangedFields: []apiFieldChange{{
+						Old: apiField{Name: "id", Type: "string", Label: "implicit"},
+						New: apiField{Name: "id", Type: "int64", Label: "implicit"},
+					}},
+				}}
+			},
+		},
+		{
+			name: "added streaming method",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (Item); rpc Watch(GetRequest) returns (stream Item); }",
+			want: func(d *apiDiff) { d.AddedMethods = []string{"diff.Items/Watch"} },
+		},
+		{
+			name: "removed streaming method",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); rpc Upload(stream Item) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			want: func(d *apiDiff) { d.RemovedMethods = []string{"diff.Items/Upload"} },
+		},
+		{
+			name: "changed streaming",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); rpc Chat(stream Item) returns (Item); }",
+			new:  "service Items { rpc Get(GetRequest) returns (stream Item); rpc Chat(stream Item) returns (stream Item); }",
+			want: func(d *apiDiff) {
+				d.ChangedMethods = []apiMethodDiff{
+					{Method: "diff.Items/Chat", Streaming: &apiRename{From: "client streaming", To: "bidirectional streaming"}},
+					{Method: "diff.Items/Get", Streaming: &apiRename{From: "unary", To: "server streaming"}},
+				}
+			},
+		},
+		{
+			name: "streaming isn't renamed",
+			old:  "service Items { rpc Get(GetRequest) returns (Item); }",
+			new:  "service Items { rpc Watch(GetRequest) returns (stream Item); }",
+			want: func(d *apiDiff) {
+				d.AddedMethods = []string{"diff.Items/Watch"}
+				d.RemovedMethods = []string{"diff.Items/Get"}
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			want := &apiDiff{
+				AddedServices:   []string{},
+				RemovedServices: []string{},
+				RenamedServices: []apiRename{},
+				AddedMethods:    []string{},
+				RemovedMethods:  []string{},
+				RenamedMethods:  []apiRename{},
+				ChangedMethods:  []apiMethodDiff{},
+			}
+			c.want(want)
+			got := diffServices(parseDiffServices(t, c.old), parseDiffServices(t, c.new))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got diff\n%s\nwant\n%s", mustMarshalIndent(t, got), mustMarshalIndent(t, want))
+			}
+		})
+	}
+}
+
+// TestDiffExport checks that the API recorded by exports is the API of their
+// files, whatever the protocol and filters they were generated with.
+func TestDiffExport(t *testing.T) {
+	for _, c := range []struct {
+		files     []string
+		parameter string
+	}{
+		{[]string{"services.proto"}, ""},
+		{[]string{"filters.proto", "services.proto"}, "exclude=**.Admin*;/fixtures\\.filters\\.\\w+Admin/,deprecated=mark"},
+		{[]string{"streaming.proto"}, "protocol=grpc"},
+		{[]string{"streaming.proto"}, "protocol=grpc-web,exclude=**.Chat;**.Upload"},
+	} {
+		req, err := parseProtoFiles(c.files, []string{filepath.Join("testdata", "protos"), filepath.Join("..", "proto")}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := descriptorServices(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := apiServices{}
+		for _, file := range generateFixtures(t, c.files, c.parameter).File {
+			if !strings.HasSuffix(file.GetName(), "-insomnia-env.json") {
+				continue
+			}
+			services, err := exportServices([]byte(file.GetContent()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, methods := range services {
+				got[name] = methods
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s with %q: got services\n%s\nwant\n%s", strings.Join(c.files, ", "), c.parameter, mustMarshalIndent(t, got), mustMarshalIndent(t, want))
+		}
+	}
+}
+
+func TestExportServicesWithoutAPI(t *testing.T) {
+	export := `{"resources": [{"_type": "workspace", "_id": "workspace-a.proto-a"}, {"_type": "request", "_id": "request-Items-Get"}]}`
+	_, err := exportServices([]byte(export))
+	if err == nil || !strings.Contains(err.Error(), "generate it again") {
+		t.Errorf("got error %v, want it to ask for the export to be generated again", err)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	d := diffServices(
+		parseDiffServices(t, "service Items { rpc Get(GetRequest) returns (Item); }"),
+		parseDiffServices(t, "service Items { rpc Get(GetRequest) returns (stream Item); rpc List(ListRequest) returns (stream Item); }"),
+	)
+	var b bytes.Buffer
+	writeDiff(&b, d)
+	want := `Added methods:
+  + diff.Items/List
+Changed methods:
+  diff.Items/Get
+    streaming: unary -> server streaming
+`
+	if b.String() != want {
+		t.Errorf("got report\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+// parseDiffServices returns the services of a file declaring services,
+// along with the messages they use.
+func parseDiffServices(t *testing.T, services string) apiServices {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "api.proto"), []byte(diffHeader+diffMessages+services+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	req, err := parseProtoFiles([]string{"api.proto"}, []string{dir}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := descriptorServices(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func mustMarshalIndent(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
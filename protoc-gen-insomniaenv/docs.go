@@ -0,0 +1,158 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// serviceDescription returns the Markdown description of a service's request
+// group, built from the comments on the service.
+func (e *insomniaenv) serviceDescription(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	var sections []string
+	if e.params.deprecated == deprecatedMark && service.GetOptions().GetDeprecated() {
+		sections = append(sections, deprecatedNotice)
+	}
+	if comments, err := e.registry.ServiceComments(file, service); err == nil {
+		if text := commentText(comments); text != "" {
+			sections = append(sections, text)
+		}
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// deprecatedNotice opens the descriptions of deprecated services and methods
+// when deprecated=mark is set.
+const deprecatedNotice = "**Deprecated.**"
+
+// methodDescription returns the Markdown description of a method's request,
+// built from the comments on the method and followed by tables documenting
+// the fields of its input and output messages, and an example response, or
+// example streams of messages for streaming methods.
+func (e *insomniaenv) methodDescription(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto, mocks methodMocks) string {
+	var sections []string
+	if e.params.deprecated == deprecatedMark && methodDeprecated(service, method) {
+		sections = append(sections, deprecatedNotice)
+	}
+	if comments, err := e.registry.MethodComments(file, service, method); err == nil {
+		if text := commentText(comments); text != "" {
+			sections = append(sections, text)
+		}
+	}
+	if msg := e.registry.MessageDefinition(method.GetInputType()); msg != nil {
+		sections = append(sections, e.messageTable("Request", msg))
+		if method.GetClientStreaming() {
+			sections = append(sections, "#### Example request stream\n\n"+jsonBlocks(mocks.requests))
+		}
+	}
+	if msg := e.registry.MessageDefinition(method.GetOutputType()); msg != nil {
+		sections = append(sections, e.messageTable("Response", msg))
+		if method.GetServerStreaming() {
+			sections = append(sections, "#### Example response stream\n\n"+jsonBlocks(mocks.responses))
+		} else {
+			sections = append(sections, "#### Example response\n\n"+jsonBlocks([]string{mocks.response}))
+		}
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// jsonBlocks returns a Markdown JSON code block per message.
+func jsonBlocks(messages []string) string {
+	blocks := make([]string, len(messages))
+	for i, message := range messages {
+		blocks[i] = "```json\n" + message + "\n```"
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// messageTable returns a Markdown section headed title, describing the
+// message and listing its fields.
+func (e *insomniaenv) messageTable(title string, messageDefinition *typemap.MessageDefinition) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s: `%s`\n", title, strings.TrimPrefix(messageDefinition.ProtoName(), "."))
+	if text := commentText(messageDefinition.Comments); text != "" {
+		fmt.Fprintf(&b, "\n%s\n", text)
+	}
+	if len(messageDefinition.Descriptor.Field) == 0 {
+		b.WriteString("\nThis message has no fields.")
+		return b.String()
+	}
+
+	b.WriteString("\n| Field | Type | Label | Description |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, field := range messageDefinition.Descriptor.Field {
+		description := ""
+		if comments, err := e.registry.FieldComments(messageDefinition, field); err == nil {
+			description = commentText(comments)
+		}
+		if field.DefaultValue != nil {
+			description = strings.TrimSpace(fmt.Sprintf("%s\n\nDefaults to `%s`.", description, field.GetDefaultValue()))
+		}
+		description = tableCell(description)
+		name, typ, label := fieldColumns(e.presence, field)
+		if e.params.origName {
+			name = field.GetName()
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s |\n", name, typ, label, description)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// fieldColumns returns the JSON name, type and label of field, as listed in
+// message tables. The label tells the presence of the field rather than its
+// label in the proto file: "repeated", "required", "optional" for fields
+// tracking whether they're set, whatever the syntax declaring them, or
+// "implicit" for the fields of proto3 and editions which don't.
+func fieldColumns(ix *presence.Index, field *descriptor.FieldDescriptorProto) (name, typ, label string) {
+	switch {
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		label = "repeated"
+	case ix.Of(field) == presence.Required:
+		label = "required"
+	case ix.Of(field) == presence.Explicit:
+		label = "optional"
+	default:
+		label = "implicit"
+	}
+	return field.GetJsonName(), strings.TrimPrefix(fieldTypeName(field), "."), label
+}
+
+// commentText joins the leading and trailing comments of a definition into
+// Markdown paragraphs. protoc keeps the space following the comment marker,
+// which is removed from each line.
+func commentText(comments typemap.DefinitionComments) string {
+	var paragraphs []string
+	for _, comment := range []string{comments.Leading, comments.Trailing} {
+		lines := strings.Split(strings.TrimRight(comment, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(strings.TrimPrefix(line, " "), " \t")
+		}
+		if text := strings.TrimSpace(strings.Join(lines, "\n")); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// tableCell flattens text onto a single line so it fits in a Markdown table
+// cell.
+func tableCell(text string) string {
+	text = strings.Replace(text, "|", "\\|", -1)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+)
+
+// Fields of CodeGeneratorResponse which the vendored plugin package
+// predates, so they're encoded as unknown fields.
+const (
+	responseSupportedFeaturesNumber = 2
+	responseMinimumEditionNumber    = 3
+	responseMaximumEditionNumber    = 4
+)
+
+// Values of CodeGeneratorResponse.Feature.
+const (
+	featureProto3Optional   = 1
+	featureSupportsEditions = 2
+)
+
+// setSupportedFeatures tells protoc that the plugin supports proto3 optional
+// fields and edition 2023. Without it, protoc refuses to run the plugin on
+// files using them.
+func setSupportedFeatures(resp *plugin.CodeGeneratorResponse) {
+	buf := proto.NewBuffer(nil)
+	for _, field := range []struct {
+		number int32
+		value  uint64
+	}{
+		{responseSupportedFeaturesNumber, featureProto3Optional | featureSupportsEditions},
+		{responseMinimumEditionNumber, presence.Edition2023},
+		{responseMaximumEditionNumber, presence.Edition2023},
+	} {
+		buf.EncodeVarint(uint64(field.number)<<3 | proto.WireVarint)
+		buf.EncodeVarint(field.value)
+	}
+	resp.XXX_unrecognized = buf.Bytes()
+}
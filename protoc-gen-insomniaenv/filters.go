@@ -0,0 +1,161 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/options"
+)
+
+const (
+	deprecatedInclude = "include"
+	deprecatedMark    = "mark"
+	deprecatedSkip    = "skip"
+
+	// deprecatedSuffix is appended to the names of deprecated requests and
+	// folders when they are marked.
+	deprecatedSuffix = " (deprecated)"
+)
+
+// parseFilters returns the patterns of an include or exclude parameter,
+// separated by semicolons. A pattern between slashes is a regular
+// expression; any other pattern is a glob, in which * matches within a
+// single name component, ** matches across components and ? matches a single
+// character of a component. Both must match the whole name.
+func parseFilters(param, v string) ([]*regexp.Regexp, error) {
+	var filters []*regexp.Regexp
+	for _, pattern := range strings.Split(v, ";") {
+		if pattern == "" {
+			continue
+		}
+		expr := globRegexp(pattern)
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr = "^(?:" + pattern[1:len(pattern)-1] + ")$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q for %s: %v", pattern, param, err)
+		}
+		filters = append(filters, re)
+	}
+	return filters, nil
+}
+
+// globRegexp returns the anchored regular expression matching glob.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^.]*")
+		case glob[i] == '?':
+			b.WriteString("[^.]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// matchesAny reports whether any of filters matches any of names.
+func matchesAny(filters []*regexp.Regexp, names ...string) bool {
+	for _, filter := range filters {
+		for _, name := range names {
+			if filter.MatchString(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// methodIncluded reports whether requests are generated for method: it isn't
+// skipped by its options or as deprecated, and its fully-qualified name or
+// its service's matches the include filters, if any, and none of the exclude
+// filters.
+func (e *insomniaenv) methodIncluded(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) bool {
+	if options.Method(method).Skip {
+		return false
+	}
+	if e.params.deprecated == deprecatedSkip && methodDeprecated(service, method) {
+		return false
+	}
+	names := []string{protoServiceName(file, service), methodFullName(file, service, method)}
+	if len(e.params.include) > 0 && !matchesAny(e.params.include, names...) {
+		return false
+	}
+	return !matchesAny(e.params.exclude, names...)
+}
+
+// serviceIncluded reports whether a folder is generated for service: it isn't
+// skipped as deprecated and, when methods are filtered, some of its methods
+// are included.
+func (e *insomniaenv) serviceIncluded(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) bool {
+	if e.params.deprecated == deprecatedSkip && service.GetOptions().GetDeprecated() {
+		return false
+	}
+	if len(e.params.include) == 0 && len(e.params.exclude) == 0 {
+		return true
+	}
+	for _, method := range service.Method {
+		if e.methodIncluded(file, service, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileIncluded reports whether anything is generated for file, which is when
+// some of its services are included.
+func (e *insomniaenv) fileIncluded(file *descriptor.FileDescriptorProto) bool {
+	for _, service := range file.Service {
+		if e.serviceIncluded(file, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// methodDeprecated reports whether method, or the service declaring it, is
+// deprecated.
+func methodDeprecated(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) bool {
+	return service.GetOptions().GetDeprecated() || method.GetOptions().GetDeprecated()
+}
+
+// serviceTitle returns the name of the folder holding service's requests,
+// marked if the service is deprecated and deprecated=mark is set.
+func (e *insomniaenv) serviceTitle(service *descriptor.ServiceDescriptorProto) string {
+	if e.params.deprecated == deprecatedMark && service.GetOptions().GetDeprecated() {
+		return serviceFolder(service) + deprecatedSuffix
+	}
+	return serviceFolder(service)
+}
+
+// methodTitle returns the name of method's request, marked if the method or
+// its service is deprecated and deprecated=mark is set.
+func (e *insomniaenv) methodTitle(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	if e.params.deprecated == deprecatedMark && methodDeprecated(service, method) {
+		return method.GetName() + deprecatedSuffix
+	}
+	return method.GetName()
+}
@@ -0,0 +1,468 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/insomnia"
+	"github.com/thesilentg/proto-to-insomnia/mock"
+	"github.com/thesilentg/proto-to-insomnia/options"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+func (e *insomniaenv) generate(file *descriptor.FileDescriptorProto) ([]*plugin.CodeGeneratorResponse_File, error) {
+	resp := new(plugin.CodeGeneratorResponse_File)
+	if !e.fileIncluded(file) {
+		return nil, nil
+	}
+
+	export := insomnia.NewExport("protoc-gen-insomniaenv")
+	workspace, workspaceID := generateWorkspace(file)
+	export.Add(generatedWorkspace{Workspace: workspace, Services: fileServices(e.registry, e.presence, file)})
+	export.Add(e.generateEnvironment(workspaceID, file)...)
+	if e.params.protocol == protocolGRPC {
+		export.Add(e.generateProtoFiles(workspaceID, file)...)
+	}
+	methods, bodyFiles := e.generateMethods(workspaceID, file)
+	export.Add(methods...)
+	if e.params.unitTests {
+		export.Add(e.generateUnitTests(workspaceID, file)...)
+	}
+
+	fileWithoutPath := strings.TrimSuffix(file.GetName(), filepath.Ext(file.GetName()))
+	resp.Name = proto.String(fmt.Sprintf("%s-insomnia-env.json", fileWithoutPath))
+	if e.params.merge != "" {
+		if err := e.mergeExport(resp.GetName(), workspaceID, export); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := export.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp.Content = proto.String(string(b))
+
+	return append([]*plugin.CodeGeneratorResponse_File{resp}, bodyFiles...), nil
+}
+
+// methodFullName returns the fully-qualified name of method, which
+// diagnostics refer to it by.
+func methodFullName(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	return protoServiceName(file, service) + "." + method.GetName()
+}
+
+// requestID returns the ID of the request generated for method.
+func requestID(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	return fmt.Sprintf("request-%s-%s", service.GetName(), method.GetName())
+}
+
+// generateMethods returns a request group per service and a request per
+// method. When protobuf bodies are stored as files, it also returns those
+// files.
+func (e *insomniaenv) generateMethods(workspaceID string, file *descriptor.FileDescriptorProto) ([]interface{}, []*plugin.CodeGeneratorResponse_File) {
+	resources := []interface{}{}
+	bodyFiles := []*plugin.CodeGeneratorResponse_File{}
+	var requestGroupID string
+	var folderIDs map[string]string
+	visitService := func(service *descriptor.ServiceDescriptorProto) {
+		requestGroupID = fmt.Sprintf("request_group-%s", *service.Name)
+		folderIDs = map[string]string{}
+		environment := map[string]string{}
+		if protocol := newHTTPProtocol(e.params.protocol); protocol != nil {
+			route := e.serviceRoute(protocol, file, service)
+			environment[route.variable] = fmt.Sprintf("{{ %s }}%s", baseURLVariable(service), route.servicePath)
+		}
+		resources = append(resources, insomnia.RequestGroup{
+			Resource: insomnia.Resource{
+				Type:     "request_group",
+				ID:       requestGroupID,
+				ParentID: &workspaceID,
+				Name:     e.serviceTitle(service),
+			},
+			Description: e.serviceDescription(file, service),
+			Environment: environment,
+		})
+	}
+	visitMethod := func(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto, mocks methodMocks) {
+		groupID := requestGroupID
+		if folder := options.Method(method).Folder; folder != "" {
+			// Methods sharing a folder share the request group holding it.
+			id, ok := folderIDs[folder]
+			if !ok {
+				id = fmt.Sprintf("%s-%s", requestGroupID, folder)
+				folderIDs[folder] = id
+				parentID := requestGroupID
+				resources = append(resources, insomnia.RequestGroup{
+					Resource: insomnia.Resource{
+						Type:     "request_group",
+						ID:       id,
+						ParentID: &parentID,
+						Name:     folder,
+					},
+					Environment: map[string]string{},
+				})
+			}
+			groupID = id
+		}
+
+		request, bodyFile, err := e.generateRequest(file, service, method, groupID, "", e.methodTitle(service, method), mocks.requestMessages(), mocks)
+		if err != nil {
+			e.diags.Errorf(method, "method %s: request can't be generated: %v", methodFullName(file, service, method), err)
+			return
+		}
+		resources = append(resources, request)
+		if bodyFile != nil {
+			bodyFiles = append(bodyFiles, bodyFile)
+		}
+
+		// Protobuf variants are only generated for unary methods, whose body is
+		// a single message.
+		if e.params.protobufBody != "" && !isStreaming(method) {
+			protocol := newHTTPProtocol(e.params.protocol)
+			request, bodyFile, err := e.generateProtobufRequest(protocol, file, service, method, groupID, mocks)
+			if err != nil {
+				e.diags.Errorf(method, "method %s: protobuf request can't be generated: %v", methodFullName(file, service, method), err)
+			} else {
+				resources = append(resources, request)
+				if bodyFile != nil {
+					bodyFiles = append(bodyFiles, bodyFile)
+				}
+			}
+		}
+
+		for i, example := range options.Method(method).Examples {
+			name := fmt.Sprintf("%s (%s)", e.methodTitle(service, method), example.Name)
+			request, bodyFile, err := e.generateRequest(file, service, method, groupID, fmt.Sprintf("-example-%d", i+1), name, []string{example.Body}, mocks)
+			if err != nil {
+				e.diags.Errorf(method, "method %s: request of example %q can't be generated: %v", methodFullName(file, service, method), example.Name, err)
+				continue
+			}
+			resources = append(resources, request)
+			if bodyFile != nil {
+				bodyFiles = append(bodyFiles, bodyFile)
+			}
+		}
+	}
+	e.walkMethods(file, visitService, visitMethod)
+	return resources, bodyFiles
+}
+
+// generateRequest returns a request to method sending messages, either a
+// gRPC request or an HTTP request depending on the protocol. gRPC requests
+// only hold the first message, which Insomnia sends as many times as asked to
+// client streaming methods. The request's ID is the method's request ID
+// followed by idSuffix. HTTP requests with a binary body send it from a file,
+// which is also returned.
+func (e *insomniaenv) generateRequest(
+	file *descriptor.FileDescriptorProto,
+	service *descriptor.ServiceDescriptorProto,
+	method *descriptor.MethodDescriptorProto,
+	requestGroupID string,
+	idSuffix string,
+	name string,
+	messages []string,
+	mocks methodMocks,
+) (interface{}, *plugin.CodeGeneratorResponse_File, error) {
+	if e.params.protocol == protocolGRPC {
+		return insomnia.GrpcRequest{
+			Resource: insomnia.Resource{
+				Type:     "grpc_request",
+				ID:       fmt.Sprintf("grpc_request-%s-%s%s", service.GetName(), method.GetName(), idSuffix),
+				ParentID: &requestGroupID,
+				Name:     name,
+			},
+			Description:     e.methodDescription(file, service, method, mocks),
+			URL:             fmt.Sprintf("{{ %s }}", baseURLVariable(service)),
+			ProtoFileID:     protoFileID(file.GetName()),
+			ProtoMethodName: grpcMethodName(file, service, method),
+			Body: insomnia.GrpcRequestBody{
+				Text: messages[0],
+			},
+			Metadata: e.grpcMetadata(service, method),
+		}, nil, nil
+	}
+
+	protocol := newHTTPProtocol(e.params.protocol)
+	call, err := protocol.newCall(e, method, messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bodyFile *plugin.CodeGeneratorResponse_File
+	if call.binaryBody != nil {
+		fileWithoutPath := strings.TrimSuffix(file.GetName(), filepath.Ext(file.GetName()))
+		call.body.FileName = fmt.Sprintf("%s-streams/%s/%s%s.bin", fileWithoutPath, service.GetName(), method.GetName(), idSuffix)
+		bodyFile = &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(call.body.FileName),
+			Content: proto.String(string(call.binaryBody)),
+		}
+	}
+	return insomnia.Request{
+		Resource: insomnia.Resource{
+			Type:     "request",
+			ID:       requestID(service, method) + idSuffix,
+			ParentID: &requestGroupID,
+			Name:     name,
+		},
+		Description:    e.methodDescription(file, service, method, mocks),
+		Method:         call.method,
+		Parameters:     call.parameters,
+		Headers:        withHeaders(call.headers, e.methodHeaders(service, method)),
+		URL:            e.methodRoute(protocol, file, service, method).url(),
+		Body:           call.body,
+		Authentication: e.methodAuth(service, method).insomniaAuthentication(),
+	}, bodyFile, nil
+}
+
+// generateProtobufRequest returns a variant of a method's request which sends
+// body encoded as binary protobuf. Insomnia can't hold binary data in a text
+// body, so the encoded message is written to a separate file that the
+// request points at.
+func (e *insomniaenv) generateProtobufRequest(
+	protocol httpProtocol,
+	file *descriptor.FileDescriptorProto,
+	service *descriptor.ServiceDescriptorProto,
+	method *descriptor.MethodDescriptorProto,
+	requestGroupID string,
+	mocks methodMocks,
+) (insomnia.Request, *plugin.CodeGeneratorResponse_File, error) {
+	msg := e.registry.MessageDefinition(method.GetInputType())
+	encoded, err := e.mocks.Encode(msg, mocks.request)
+	if err != nil {
+		return insomnia.Request{}, nil, err
+	}
+
+	request := insomnia.Request{
+		Resource: insomnia.Resource{
+			Type:     "request",
+			ID:       fmt.Sprintf("request-%s-%s-protobuf", service.GetName(), method.GetName()),
+			ParentID: &requestGroupID,
+			Name:     fmt.Sprintf("%s (protobuf)", e.methodTitle(service, method)),
+		},
+		Description: e.methodDescription(file, service, method, mocks),
+		Method:      "POST",
+		Headers:     withHeaders(protocol.protobufHeaders(), e.methodHeaders(service, method)),
+		URL:         e.methodRoute(protocol, file, service, method).url(),
+		Body: insomnia.RequestBody{
+			MimeType: protocol.protobufContentType(),
+		},
+		Authentication: e.methodAuth(service, method).insomniaAuthentication(),
+	}
+
+	fileWithoutPath := strings.TrimSuffix(file.GetName(), filepath.Ext(file.GetName()))
+	bodyFileName := fmt.Sprintf("%s-protobuf/%s/%s.bin", fileWithoutPath, service.GetName(), method.GetName())
+	request.Body.FileName = bodyFileName
+	return request, &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(bodyFileName),
+		Content: proto.String(string(encoded)),
+	}, nil
+}
+
+// methodMocks holds the mock messages generated for a method, encoded as
+// JSON.
+type methodMocks struct {
+	request   string   // Mock of the method's input type, used as the request body.
+	response  string   // Mock of the method's output type, shown as an example response.
+	requests  []string // Mocks of the input type sent by client streaming methods, starting with request.
+	responses []string // Mocks of the output type sent by server streaming methods, starting with response.
+}
+
+// walkMethods visits every service in file, and every method within each
+// service, in declaration order. visitService is called before any of the
+// service's methods are visited. visitMethod receives the mock messages
+// generated for the method. Streaming methods the protocol can't call are
+// reported and skipped.
+func (e *insomniaenv) walkMethods(
+	file *descriptor.FileDescriptorProto,
+	visitService func(service *descriptor.ServiceDescriptorProto),
+	visitMethod func(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto, mocks methodMocks),
+) {
+	for _, service := range file.Service {
+		if !e.serviceIncluded(file, service) {
+			continue
+		}
+		visitService(service)
+
+		for _, method := range service.Method {
+			if !e.methodIncluded(file, service, method) {
+				continue
+			}
+			if !e.streamingSupported(method) {
+				e.diags.Warnf(method, "method %s: %s methods can't be called with protocol %q in format %q, so it has no request", methodFullName(file, service, method), streamingKind(method), e.params.protocol, e.params.format)
+				continue
+			}
+			mock.Seed(method.GetName())
+			strategy := e.mockStrategy(method)
+
+			// The response is mocked after the request, so that the request
+			// body doesn't depend on the output type.
+			var mocks methodMocks
+			if msg := e.registry.MessageDefinition(method.GetInputType()); msg != nil {
+				mocks.request = e.mocks.MessageUsing(msg, strategy)
+				e.checkRoundTrip(file, service, method, msg, "request body", mocks.request)
+				for _, example := range options.Method(method).Examples {
+					e.checkRoundTrip(file, service, method, msg, fmt.Sprintf("body of example %q", example.Name), example.Body)
+				}
+			} else {
+				e.diags.Warnf(method, "method %s: input type %s could not be found, so its request has no body", methodFullName(file, service, method), method.GetInputType())
+			}
+			if msg := e.registry.MessageDefinition(method.GetOutputType()); msg != nil {
+				mocks.response = e.mocks.MessageUsing(msg, strategy)
+				e.checkRoundTrip(file, service, method, msg, "example response", mocks.response)
+			} else {
+				e.diags.Warnf(method, "method %s: output type %s could not be found, so it has no example response", methodFullName(file, service, method), method.GetOutputType())
+			}
+			// The rest of each stream is mocked last, so that unary methods
+			// and the first messages of streams are mocked the same way.
+			if msg := e.registry.MessageDefinition(method.GetInputType()); msg != nil && method.GetClientStreaming() {
+				mocks.requests = e.streamMocks(msg, strategy, mocks.request)
+			}
+			if msg := e.registry.MessageDefinition(method.GetOutputType()); msg != nil && method.GetServerStreaming() {
+				mocks.responses = e.streamMocks(msg, strategy, mocks.response)
+			}
+			visitMethod(service, method, mocks)
+		}
+	}
+}
+
+// checkRoundTrip warns if body, a message of type msg described by what,
+// doesn't round-trip through the JSON mapping with the options mocks are
+// written with, when the validate_json parameter is set.
+func (e *insomniaenv) checkRoundTrip(
+	file *descriptor.FileDescriptorProto,
+	service *descriptor.ServiceDescriptorProto,
+	method *descriptor.MethodDescriptorProto,
+	msg *typemap.MessageDefinition,
+	what string,
+	body string,
+) {
+	if !e.params.validateJSON {
+		return
+	}
+	if err := e.mocks.RoundTrip(msg, body); err != nil {
+		e.diags.Warnf(method, "method %s: %s doesn't round-trip through the JSON mapping: %v", methodFullName(file, service, method), what, err)
+	}
+}
+
+// mockStrategy returns the strategy mocking the messages of method: its
+// mock_strategy option, or else the mock_strategy parameter.
+func (e *insomniaenv) mockStrategy(method *descriptor.MethodDescriptorProto) mock.Strategy {
+	if name := options.Method(method).MockStrategy; name != "" {
+		// checkOptions has already rejected unknown strategies.
+		if strategy, err := mock.ParseStrategy(name); err == nil {
+			return strategy
+		}
+	}
+	return e.mocks.Strategy
+}
+
+// localEnvironment describes one of the sub environments generated for
+// every workspace.
+type localEnvironment struct {
+	id   string
+	name string
+	host string
+	tls  bool
+}
+
+var localEnvironments = []localEnvironment{
+	{id: "LocalhostHttps", name: "Localhost - Https", host: "localhost:8000", tls: true},
+	{id: "LocalhostHttp", name: "Localhost - Http", host: "localhost:8000", tls: false},
+}
+
+// baseURL returns the URL requests using protocol are sent to in env.
+func (env localEnvironment) baseURL(protocol string) string {
+	scheme := "http"
+	if protocol == protocolGRPC {
+		scheme = "grpc"
+	}
+	if env.tls {
+		scheme += "s"
+	}
+	return fmt.Sprintf("%s://%s", scheme, env.host)
+}
+
+// data returns the variables of env: base_url and the other base URL
+// variables, which all hold the same URL.
+func (env localEnvironment) data(protocol string, variables []string) map[string]string {
+	data := map[string]string{
+		"base_url": env.baseURL(protocol),
+	}
+	for _, variable := range variables {
+		data[variable] = env.baseURL(protocol)
+	}
+	return data
+}
+
+// generateEnvironment returns the base environment, holding an empty value
+// for each credential used by the requests of file, and the local
+// environments.
+func (e *insomniaenv) generateEnvironment(workspaceID string, file *descriptor.FileDescriptorProto) []interface{} {
+	baseEnv := insomnia.Environment{
+		Resource: insomnia.Resource{
+			Type:     "environment",
+			ID:       "BaseEnvironment",
+			ParentID: &workspaceID,
+			Name:     "Base",
+		},
+		Data: map[string]string{},
+	}
+	for _, variable := range e.fileAuthVariables(file) {
+		baseEnv.Data[variable] = ""
+	}
+
+	str := "BaseEnvironment"
+	resources := []interface{}{baseEnv}
+	for _, env := range localEnvironments {
+		resources = append(resources, insomnia.Environment{
+			Resource: insomnia.Resource{
+				Type:     "environment",
+				ID:       env.id,
+				ParentID: &str,
+				Name:     env.name,
+			},
+			Data: env.data(e.params.protocol, e.fileBaseURLVariables(file)),
+		})
+	}
+	return resources
+}
+
+func generateWorkspace(file *descriptor.FileDescriptorProto) (insomnia.Workspace, string) {
+	id := fmt.Sprintf("workspace-%s-%s", file.GetName(), file.GetPackage())
+	return insomnia.Workspace{
+		Resource: insomnia.Resource{
+			Type:     "workspace",
+			ID:       id,
+			ParentID: nil,
+			Name:     getFileName(*file.Name),
+		},
+	}, id
+}
+
+func getFileName(s string) string {
+	return strings.Title(trimSuffix(s, protoFileExtension))
+}
+
+func trimSuffix(s, suffix string) string {
+	if strings.HasSuffix(s, suffix) {
+		s = s[:len(s)-len(suffix)]
+	}
+	return s
+}
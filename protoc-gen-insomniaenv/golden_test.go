@@ -0,0 +1,493 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// fixtureFiles are the fixtures under testdata/protos. common/types.proto
+// and google/api/field_behavior.proto define no services, so nothing is
+// generated for them. streaming.proto is left out, as Twirp can't call its
+// streaming methods.
+var fixtureFiles = []string{
+	"scalars.proto",
+	"enums.proto",
+	"nested.proto",
+	"maps.proto",
+	"oneofs.proto",
+	"wkt.proto",
+	"common/types.proto",
+	"imports.proto",
+	"services.proto",
+	"proto2.proto",
+	"presence.proto",
+	"editions.proto",
+	"google/api/field_behavior.proto",
+	"strategies.proto",
+	"filters.proto",
+}
+
+// goldenCases are generated from the fixtures, each into its own directory
+// under testdata/golden.
+var goldenCases = []struct {
+	name      string
+	files     []string
+	parameter string
+}{
+	{name: "default", files: fixtureFiles},
+	{name: "connect", files: []string{"scalars.proto", "services.proto"}, parameter: "protocol=connect,auth=bearer,routes=true"},
+	{name: "grpc", files: []string{"wkt.proto", "imports.proto", "services.proto", "presence.proto", "editions.proto"}, parameter: "protocol=grpc"},
+	{name: "unit_tests", files: []string{"enums.proto", "maps.proto", "services.proto"}, parameter: "unit_tests=true"},
+	{name: "protobuf_body", files: []string{"nested.proto", "services.proto"}, parameter: "protobuf_body=file,path_prefix=/api"},
+	{name: "protobuf_body_connect", files: []string{"services.proto"}, parameter: "protocol=connect,protobuf_body=file"},
+	{name: "extensions", files: []string{"proto2.proto"}, parameter: "extensions=true,unit_tests=true,protobuf_body=file"},
+	{name: "presence_omit", files: []string{"proto2.proto", "presence.proto", "editions.proto"}, parameter: "presence=omit,unit_tests=true"},
+	{name: "mock_strategy", files: []string{"google/api/field_behavior.proto", "strategies.proto"}, parameter: "mock_strategy=minimal,repeated_count=2"},
+	{name: "jsonpb_options", files: []string{"scalars.proto", "enums.proto", "maps.proto", "wkt.proto"}, parameter: "orig_name=true,enums_as_ints=true,emit_defaults=false,validate_json=true"},
+	{name: "filters", files: []string{"filters.proto", "services.proto"}, parameter: "exclude=**.Admin*;/fixtures\\.filters\\.\\w+Admin/,deprecated=mark,unit_tests=true"},
+	{name: "filters_include", files: []string{"filters.proto", "services.proto"}, parameter: "include=fixtures.filters.*,deprecated=skip,format=hoppscotch"},
+	{name: "deprecated_hoppscotch", files: []string{"filters.proto"}, parameter: "format=hoppscotch,deprecated=mark"},
+	{name: "streaming_grpc", files: []string{"streaming.proto"}, parameter: "protocol=grpc"},
+	{name: "streaming_connect", files: []string{"streaming.proto"}, parameter: "protocol=connect,exclude=**.Chat,routes=true"},
+	{name: "streaming_grpc_web", files: []string{"streaming.proto"}, parameter: "protocol=grpc-web,exclude=**.Chat;**.Upload"},
+	{name: "hoppscotch", files: []string{"oneofs.proto", "services.proto"}, parameter: "format=hoppscotch,auth=apikey"},
+	{name: "hoppscotch_headers", files: []string{"services.proto"}, parameter: "format=hoppscotch,headers=testdata/headers/templated.txt"},
+}
+
+// TestGolden generates each case and compares the files generated with the
+// golden files. Run with -update to regenerate the golden files after
+// changing the output on purpose.
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := generateFixtures(t, c.files, c.parameter)
+			dir := filepath.Join("testdata", "golden", c.name)
+			if *update {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatal(err)
+				}
+				if err := writeResponseFiles(dir, resp); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			generated := map[string]bool{}
+			for _, file := range resp.File {
+				generated[file.GetName()] = true
+				checkGeneratedFile(t, file)
+				want, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file.GetName())))
+				if err != nil {
+					t.Errorf("%s: %v (run go test -update to add it)", file.GetName(), err)
+					continue
+				}
+				if got := file.GetContent(); got != string(want) {
+					t.Errorf("%s differs from its golden file (run go test -update to update it)\n%s", file.GetName(), firstDifference(string(want), got))
+				}
+			}
+			for _, name := range goldenFiles(t, dir) {
+				if !generated[name] {
+					t.Errorf("%s has a golden file but wasn't generated", name)
+				}
+			}
+		})
+	}
+}
+
+// TestGenerateDeterministic checks that generating twice gives the same
+// output, whatever the state of the random source beforehand.
+func TestGenerateDeterministic(t *testing.T) {
+	rand.Seed(1)
+	first := generateFixtures(t, fixtureFiles, "")
+	rand.Seed(2)
+	second := generateFixtures(t, fixtureFiles, "")
+	if len(first.File) != len(second.File) {
+		t.Fatalf("generated %d files, then %d", len(first.File), len(second.File))
+	}
+	for i, file := range first.File {
+		if file.GetContent() != second.File[i].GetContent() {
+			t.Errorf("%s differs between runs\n%s", file.GetName(), firstDifference(file.GetContent(), second.File[i].GetContent()))
+		}
+	}
+}
+
+// TestValidateJSON checks that the mocks of every fixture round-trip through
+// the JSON mapping with each mock strategy and jsonpb option, since
+// generateFixtures fails on the warnings of bodies which don't.
+func TestValidateJSON(t *testing.T) {
+	for _, parameter := range []string{
+		"validate_json=true,extensions=true",
+		"validate_json=true,mock_strategy=zero,emit_defaults=false",
+		"validate_json=true,mock_strategy=minimal,presence=omit",
+		"validate_json=true,mock_strategy=random,orig_name=true",
+	} {
+		generateFixtures(t, fixtureFiles, parameter)
+	}
+}
+
+// TestSupportedFeatures checks that responses tell protoc the plugin supports
+// proto3 optional fields and edition 2023, which protoc requires before
+// running it on files using them.
+func TestSupportedFeatures(t *testing.T) {
+	resp := generateFixtures(t, []string{"presence.proto"}, "")
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[uint64]uint64{}
+	buf := proto.NewBuffer(b)
+	for {
+		key, err := buf.DecodeVarint()
+		if err != nil {
+			break
+		}
+		if key&7 == proto.WireBytes {
+			if _, err := buf.DecodeRawBytes(false); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		v, err := buf.DecodeVarint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[key>>3] = v
+	}
+	want := map[uint64]uint64{2: 3, 3: 1000, 4: 1000}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got response fields %v, want %v", got, want)
+	}
+}
+
+// TestProtobufBodyFiles checks that binary bodies are written byte for byte
+// to the files their requests point at, including the bytes above 0x7f that
+// text bodies can't carry.
+func TestProtobufBodyFiles(t *testing.T) {
+	resp := generateFixtures(t, []string{"proto2.proto"}, "protobuf_body=file")
+	files := map[string]string{}
+	for _, file := range resp.File {
+		files[file.GetName()] = file.GetContent()
+	}
+	var export struct {
+		Resources []struct {
+			ID   string `json:"_id"`
+			Body struct {
+				Text     string `json:"text"`
+				FileName string `json:"fileName"`
+			} `json:"body"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal([]byte(files["proto2-insomnia-env.json"]), &export); err != nil {
+		t.Fatal(err)
+	}
+	bodies := 0
+	for _, r := range export.Resources {
+		if strings.Contains(r.Body.Text, "{% base64") {
+			t.Errorf("%s: binary body embedded in text: %s", r.ID, r.Body.Text)
+		}
+		if r.Body.FileName == "" {
+			continue
+		}
+		body, ok := files[r.Body.FileName]
+		if !ok {
+			t.Errorf("%s: body file %s wasn't generated", r.ID, r.Body.FileName)
+			continue
+		}
+		bodies++
+		if !hasHighByte(body) {
+			t.Errorf("%s: body file %s has no bytes above 0x7f, so it doesn't test them", r.ID, r.Body.FileName)
+		}
+		if err := checkWireFormat([]byte(body)); err != nil {
+			t.Errorf("%s: body file %s: %v", r.ID, r.Body.FileName, err)
+		}
+	}
+	if bodies == 0 {
+		t.Error("no request has a body file")
+	}
+}
+
+func hasHighByte(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWireFormat checks that b is a sequence of well-formed protobuf fields.
+func checkWireFormat(b []byte) error {
+	for len(b) > 0 {
+		key, n := proto.DecodeVarint(b)
+		if n == 0 {
+			return fmt.Errorf("truncated key at %x", b)
+		}
+		b = b[n:]
+		size := 0
+		switch key & 7 {
+		case proto.WireVarint:
+			if _, n := proto.DecodeVarint(b); n > 0 {
+				size = n
+			}
+		case proto.WireFixed64:
+			size = 8
+		case proto.WireBytes:
+			if length, n := proto.DecodeVarint(b); n > 0 {
+				size = n + int(length)
+			}
+		case proto.WireFixed32:
+			size = 4
+		case proto.WireStartGroup, proto.WireEndGroup:
+			continue
+		default:
+			return fmt.Errorf("field %d has unexpected wire type %d", key>>3, key&7)
+		}
+		if size == 0 || size > len(b) {
+			return fmt.Errorf("field %d is truncated", key>>3)
+		}
+		b = b[size:]
+	}
+	return nil
+}
+
+// generateFixtures runs the generator on the fixtures named by files, as
+// protoc would with parameter. The random source is reset to a fixed seed,
+// although mocks are seeded by the name of their method anyway.
+func generateFixtures(t *testing.T, files []string, parameter string) *plugin.CodeGeneratorResponse {
+	t.Helper()
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "protos"), filepath.Join("..", "proto")}}
+	protoFiles, err := parser.ParseFiles(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := newCodeGeneratorRequest(protoFiles, files, parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rand.Seed(1)
+	var stderr bytes.Buffer
+	e := insomniaenv{stderr: &stderr}
+	resp, err := e.Generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("unexpected diagnostics:\n%s", stderr.String())
+	}
+	if len(resp.File) == 0 {
+		t.Fatal("no files were generated")
+	}
+	return resp
+}
+
+// checkGeneratedFile checks that JSON files are valid, and that Insomnia
+// exports can be imported.
+func checkGeneratedFile(t *testing.T, file *plugin.CodeGeneratorResponse_File) {
+	t.Helper()
+	name := file.GetName()
+	if !strings.HasSuffix(name, ".json") {
+		return
+	}
+	if !json.Valid([]byte(file.GetContent())) {
+		t.Errorf("%s is not valid JSON", name)
+		return
+	}
+	if strings.HasSuffix(name, "-insomnia-env.json") {
+		for _, err := range checkInsomniaExport([]byte(file.GetContent())) {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+// insomniaResourceFields lists the fields Insomnia requires of each type of
+// resource, besides _id, _type, parentId and name.
+var insomniaResourceFields = map[string][]string{
+	"workspace":       {},
+	"environment":     {"data"},
+	"request_group":   {"environment"},
+	"request":         {"method", "url", "headers", "body"},
+	"grpc_request":    {"url", "protoFileId", "protoMethodName", "body", "metadata"},
+	"proto_file":      {"protoText"},
+	"proto_directory": {},
+	"unit_test_suite": {},
+	"unit_test":       {"code", "requestId"},
+}
+
+// checkInsomniaExport checks b against the schema of the Insomnia export
+// format, returning a description of each problem found.
+func checkInsomniaExport(b []byte) []string {
+	var export struct {
+		Type         string                   `json:"_type"`
+		ExportFormat int                      `json:"__export_format"`
+		ExportSource string                   `json:"__export_source"`
+		Resources    []map[string]interface{} `json:"resources"`
+	}
+	if err := json.Unmarshal(b, &export); err != nil {
+		return []string{err.Error()}
+	}
+
+	var errs []string
+	if export.Type != "export" {
+		errs = append(errs, `_type is not "export"`)
+	}
+	if export.ExportFormat != 3 && export.ExportFormat != 4 {
+		errs = append(errs, "unsupported __export_format")
+	}
+	if export.ExportSource == "" {
+		errs = append(errs, "missing __export_source")
+	}
+
+	ids := map[string]string{}
+	for _, r := range export.Resources {
+		id, typ := stringField(r, "_id"), stringField(r, "_type")
+		if id == "" {
+			errs = append(errs, "resource without an _id")
+			continue
+		}
+		if _, ok := ids[id]; ok {
+			errs = append(errs, id+": duplicate _id")
+		}
+		ids[id] = typ
+	}
+
+	for _, r := range export.Resources {
+		id, typ := stringField(r, "_id"), stringField(r, "_type")
+		fields, ok := insomniaResourceFields[typ]
+		if !ok {
+			errs = append(errs, id+": unknown _type "+typ)
+			continue
+		}
+		if stringField(r, "name") == "" {
+			errs = append(errs, id+": missing name")
+		}
+		for _, field := range fields {
+			if _, ok := r[field]; !ok {
+				errs = append(errs, id+": missing "+field)
+			}
+		}
+
+		parentID := stringField(r, "parentId")
+		switch {
+		case typ == "workspace":
+			if r["parentId"] != nil {
+				errs = append(errs, id+": workspace has a parent")
+			}
+		case parentID == "":
+			errs = append(errs, id+": missing parentId")
+		case ids[parentID] == "":
+			errs = append(errs, id+": parent "+parentID+" doesn't exist")
+		}
+
+		switch typ {
+		case "request", "grpc_request":
+			body, _ := r["body"].(map[string]interface{})
+			text := stringField(body, "text")
+			if (typ == "grpc_request" || stringField(body, "mimeType") == "application/json") && !json.Valid([]byte(text)) {
+				errs = append(errs, id+": body is not valid JSON")
+			}
+		}
+		if typ == "grpc_request" && ids[stringField(r, "protoFileId")] != "proto_file" {
+			errs = append(errs, id+": proto file "+stringField(r, "protoFileId")+" doesn't exist")
+		}
+		if typ == "unit_test" && ids[stringField(r, "requestId")] != "request" {
+			errs = append(errs, id+": request "+stringField(r, "requestId")+" doesn't exist")
+		}
+	}
+	return errs
+}
+
+func TestCheckInsomniaExport(t *testing.T) {
+	b := []byte(`{
+		"_type": "export",
+		"__export_format": 3,
+		"__export_source": "test",
+		"resources": [
+			{"_type": "workspace", "_id": "w", "parentId": null, "name": "W", "description": ""},
+			{"_type": "request", "_id": "r", "parentId": "missing", "name": "R", "method": "POST", "url": "", "headers": [], "body": {"mimeType": "application/json", "text": "{"}},
+			{"_type": "folder", "_id": "f", "parentId": "w", "name": "F"},
+			{"_type": "environment", "_id": "w", "parentId": "w", "name": "E"}
+		]
+	}`)
+	got := checkInsomniaExport(b)
+	want := []string{
+		"w: duplicate _id",
+		"r: parent missing doesn't exist",
+		"r: body is not valid JSON",
+		"f: unknown _type folder",
+		"w: missing data",
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// goldenFiles returns the names of the files under dir, relative to it.
+func goldenFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		names = append(names, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// firstDifference describes the first line at which want and got differ.
+func firstDifference(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
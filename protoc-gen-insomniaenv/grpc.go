@@ -0,0 +1,95 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/insomnia"
+)
+
+func protoFileID(name string) string {
+	return fmt.Sprintf("proto_file-%s", name)
+}
+
+func protoDirectoryID(dir string) string {
+	return fmt.Sprintf("proto_directory-%s", dir)
+}
+
+// generateProtoFiles returns the proto files Insomnia needs to make gRPC
+// requests to the services in file: the file itself and everything it
+// transitively imports. Files are placed in proto directories mirroring their
+// import paths, so the imports resolve once loaded into Insomnia.
+func (e *insomniaenv) generateProtoFiles(workspaceID string, file *descriptor.FileDescriptorProto) []interface{} {
+	filesByName := map[string]*descriptor.FileDescriptorProto{}
+	for _, f := range e.files {
+		filesByName[f.GetName()] = f
+	}
+
+	resources := []interface{}{}
+	seenFiles := map[string]bool{}
+	seenDirs := map[string]bool{}
+
+	var addDir func(dir string) string
+	addDir = func(dir string) string {
+		if dir == "." || dir == "" {
+			return workspaceID
+		}
+		id := protoDirectoryID(dir)
+		if seenDirs[dir] {
+			return id
+		}
+		seenDirs[dir] = true
+		parentID := addDir(path.Dir(dir))
+		resources = append(resources, insomnia.ProtoDirectory{
+			Resource: insomnia.Resource{
+				Type:     "proto_directory",
+				ID:       id,
+				ParentID: &parentID,
+				Name:     path.Base(dir),
+			},
+		})
+		return id
+	}
+
+	var addFile func(name string)
+	addFile = func(name string) {
+		if seenFiles[name] {
+			return
+		}
+		seenFiles[name] = true
+		f, ok := filesByName[name]
+		if !ok {
+			return
+		}
+		parentID := addDir(path.Dir(name))
+		resources = append(resources, insomnia.ProtoFile{
+			Resource: insomnia.Resource{
+				Type:     "proto_file",
+				ID:       protoFileID(name),
+				ParentID: &parentID,
+				Name:     path.Base(name),
+			},
+			ProtoText: printProtoFile(f, e.presence),
+		})
+		for _, dep := range f.Dependency {
+			addFile(dep)
+		}
+	}
+	addFile(file.GetName())
+
+	return resources
+}
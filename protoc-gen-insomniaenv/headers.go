@@ -0,0 +1,110 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/options"
+)
+
+// readHeadersFile reads the headers added to every request from the file at
+// path. Each line holds a header as "Name: value". Blank lines and lines
+// starting with # are ignored.
+func readHeadersFile(path string) ([]*options.Header, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	headers := []*options.Header{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		j := strings.Index(line, ":")
+		if j <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected header as Name: value", path, i+1)
+		}
+		headers = append(headers, &options.Header{
+			Name:  strings.TrimSpace(line[:j]),
+			Value: strings.TrimSpace(line[j+1:]),
+		})
+	}
+	return headers, nil
+}
+
+// methodHeaders returns the custom headers of method's request: the headers
+// of the headers parameter, then those of its service's option and its own
+// option. A header replaces any earlier header of the same name.
+func (e *insomniaenv) methodHeaders(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) []*options.Header {
+	var headers []*options.Header
+	headers = mergeHeaders(headers, e.params.headers)
+	headers = mergeHeaders(headers, options.Service(service).Headers)
+	headers = mergeHeaders(headers, options.Method(method).Headers)
+	return headers
+}
+
+// withHeaders returns pairs, the headers of a request as Insomnia stores
+// them, with headers added. A header replaces any header of pairs with the
+// same name.
+func withHeaders(pairs []map[string]string, headers []*options.Header) []map[string]string {
+	merged := []map[string]string{}
+	for _, pair := range pairs {
+		merged = append(merged, nameValue(pair["name"], pair["value"]))
+	}
+	for _, header := range headers {
+		merged = setHeader(merged, nameValue(header.Name, header.Value))
+	}
+	return merged
+}
+
+// grpcMetadata returns the metadata of method's gRPC request: its credentials
+// and custom headers. gRPC requires metadata keys to be lowercase.
+func (e *insomniaenv) grpcMetadata(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) []map[string]string {
+	metadata := withHeaders(e.methodAuth(service, method).grpcMetadata(), e.methodHeaders(service, method))
+	for _, pair := range metadata {
+		pair["name"] = strings.ToLower(pair["name"])
+	}
+	return metadata
+}
+
+func mergeHeaders(headers []*options.Header, overrides []*options.Header) []*options.Header {
+	for _, override := range overrides {
+		replaced := false
+		for i, header := range headers {
+			if strings.EqualFold(header.Name, override.Name) {
+				headers[i] = override
+				replaced = true
+			}
+		}
+		if !replaced {
+			headers = append(headers, override)
+		}
+	}
+	return headers
+}
+
+func setHeader(pairs []map[string]string, pair map[string]string) []map[string]string {
+	for i, p := range pairs {
+		if strings.EqualFold(p["name"], pair["name"]) {
+			pairs[i] = pair
+			return pairs
+		}
+	}
+	return append(pairs, pair)
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/options"
+)
+
+// HoppscotchCollection describes the structure of a Hoppscotch collection.
+// Collections nest, so the same structure is used for the folders inside of a
+// collection.
+type HoppscotchCollection struct {
+	Version  int                    `json:"v"`
+	Name     string                 `json:"name"`
+	Folders  []HoppscotchCollection `json:"folders"`
+	Requests []HoppscotchRequest    `json:"requests"`
+	Auth     HoppscotchAuth         `json:"auth"`
+	Headers  []HoppscotchHeader     `json:"headers"`
+}
+
+// HoppscotchRequest describes the structure of a Hoppscotch REST request
+type HoppscotchRequest struct {
+	Version          string             `json:"v"`
+	Name             string             `json:"name"`
+	Method           string             `json:"method"`
+	Endpoint         string             `json:"endpoint"`
+	Params           []HoppscotchHeader `json:"params"`
+	Headers          []HoppscotchHeader `json:"headers"`
+	Auth             HoppscotchAuth     `json:"auth"`
+	Body             HoppscotchBody     `json:"body"`
+	PreRequestScript string             `json:"preRequestScript"`
+	TestScript       string             `json:"testScript"`
+}
+
+// HoppscotchHeader describes the structure of a Hoppscotch header or query
+// parameter
+type HoppscotchHeader struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Active bool   `json:"active"`
+}
+
+// HoppscotchAuth describes the structure of a Hoppscotch auth block. Only the
+// fields of its authType are set.
+type HoppscotchAuth struct {
+	AuthType       string `json:"authType"`
+	AuthActive     bool   `json:"authActive"`
+	Token          string `json:"token,omitempty"`
+	Username       string `json:"username,omitempty"`
+	Password       string `json:"password,omitempty"`
+	Key            string `json:"key,omitempty"`
+	Value          string `json:"value,omitempty"`
+	AddTo          string `json:"addTo,omitempty"`
+	AccessTokenURL string `json:"accessTokenURL,omitempty"`
+	ClientID       string `json:"clientID,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+}
+
+// HoppscotchBody describes the structure of a Hoppscotch request body. Both
+// fields are null for requests without a body.
+type HoppscotchBody struct {
+	ContentType *string `json:"contentType"`
+	Body        *string `json:"body"`
+}
+
+// HoppscotchEnvironment describes the structure of a Hoppscotch environment
+type HoppscotchEnvironment struct {
+	Name      string               `json:"name"`
+	Variables []HoppscotchVariable `json:"variables"`
+}
+
+// HoppscotchVariable describes the structure of a Hoppscotch environment
+// variable
+type HoppscotchVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// generateHoppscotch returns a collection file and an environment file for
+// file. Each service becomes a folder of the collection, holding one request
+// per method.
+func (e *insomniaenv) generateHoppscotch(file *descriptor.FileDescriptorProto) ([]*plugin.CodeGeneratorResponse_File, error) {
+	if !e.fileIncluded(file) {
+		return nil, nil
+	}
+
+	collection := HoppscotchCollection{
+		Version:  2,
+		Name:     getFileName(file.GetName()),
+		Folders:  []HoppscotchCollection{},
+		Requests: []HoppscotchRequest{},
+		Auth:     HoppscotchAuth{AuthType: "none", AuthActive: true},
+		Headers:  []HoppscotchHeader{},
+	}
+
+	visitService := func(service *descriptor.ServiceDescriptorProto) {
+		collection.Folders = append(collection.Folders, newHoppscotchFolder(e.serviceTitle(service)))
+	}
+	protocol := newHTTPProtocol(e.params.protocol)
+	newRequest := func(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto, name string, messages []string) (HoppscotchRequest, error) {
+		call, err := protocol.newCall(e, method, messages)
+		if err != nil {
+			return HoppscotchRequest{}, err
+		}
+		headers := hoppscotchHeaders(withHeaders(call.headers, e.methodHeaders(service, method)))
+		for i, header := range headers {
+			value, ok := hoppscotchTemplate(header.Value)
+			if !ok {
+				e.diags.Warnf(method, "method %s: header %s has Insomnia template tags Hoppscotch can't evaluate, so they're sent as is: %s", methodFullName(file, service, method), header.Key, header.Value)
+			}
+			headers[i].Value = value
+		}
+		request := HoppscotchRequest{
+			Version:  "1",
+			Name:     name,
+			Method:   call.method,
+			Endpoint: fmt.Sprintf("<<%s>>%s", baseURLVariable(service), e.methodRoute(protocol, file, service, method).path()),
+			Params:   hoppscotchHeaders(call.parameters),
+			Headers:  headers,
+			Auth:     e.methodAuth(service, method).hoppscotchAuth(),
+		}
+		if call.body.MimeType != "" {
+			request.Body = HoppscotchBody{
+				ContentType: &call.body.MimeType,
+				Body:        &call.body.Text,
+			}
+		}
+		return request, nil
+	}
+	visitMethod := func(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto, mocks methodMocks) {
+		request, err := newRequest(service, method, e.methodTitle(service, method), mocks.requestMessages())
+		if err != nil {
+			e.diags.Errorf(method, "method %s: request can't be generated: %v", methodFullName(file, service, method), err)
+			return
+		}
+		folder := &collection.Folders[len(collection.Folders)-1]
+		if name := options.Method(method).Folder; name != "" {
+			// Methods sharing a folder share the folder holding it.
+			i := 0
+			for i < len(folder.Folders) && folder.Folders[i].Name != name {
+				i++
+			}
+			if i == len(folder.Folders) {
+				folder.Folders = append(folder.Folders, newHoppscotchFolder(name))
+			}
+			folder = &folder.Folders[i]
+		}
+		folder.Requests = append(folder.Requests, request)
+
+		for _, example := range options.Method(method).Examples {
+			request, err := newRequest(service, method, fmt.Sprintf("%s (%s)", e.methodTitle(service, method), example.Name), []string{example.Body})
+			if err != nil {
+				e.diags.Errorf(method, "method %s: request of example %q can't be generated: %v", methodFullName(file, service, method), example.Name, err)
+				continue
+			}
+			folder.Requests = append(folder.Requests, request)
+		}
+	}
+	e.walkMethods(file, visitService, visitMethod)
+
+	environments := []HoppscotchEnvironment{}
+	for _, env := range localEnvironments {
+		variables := []HoppscotchVariable{
+			{Key: "base_url", Value: env.baseURL(e.params.protocol)},
+		}
+		for _, variable := range e.fileBaseURLVariables(file) {
+			variables = append(variables, HoppscotchVariable{Key: variable, Value: env.baseURL(e.params.protocol)})
+		}
+		for _, variable := range e.fileAuthVariables(file) {
+			variables = append(variables, HoppscotchVariable{Key: variable})
+		}
+		environments = append(environments, HoppscotchEnvironment{
+			Name:      env.name,
+			Variables: variables,
+		})
+	}
+
+	// Hoppscotch imports collections and environments as arrays, so a single
+	// file can hold several of either.
+	collectionJSON, err := marshalHoppscotch([]HoppscotchCollection{collection})
+	if err != nil {
+		return nil, err
+	}
+	environmentJSON, err := marshalHoppscotch(environments)
+	if err != nil {
+		return nil, err
+	}
+
+	fileWithoutPath := strings.TrimSuffix(file.GetName(), filepath.Ext(file.GetName()))
+	return []*plugin.CodeGeneratorResponse_File{
+		{
+			Name:    proto.String(fmt.Sprintf("%s-hoppscotch-collection.json", fileWithoutPath)),
+			Content: proto.String(string(collectionJSON)),
+		},
+		{
+			Name:    proto.String(fmt.Sprintf("%s-hoppscotch-env.json", fileWithoutPath)),
+			Content: proto.String(string(environmentJSON)),
+		},
+	}, nil
+}
+
+// newHoppscotchFolder returns an empty folder named name, which inherits the
+// authentication of its parent.
+func newHoppscotchFolder(name string) HoppscotchCollection {
+	return HoppscotchCollection{
+		Version:  2,
+		Name:     name,
+		Folders:  []HoppscotchCollection{},
+		Requests: []HoppscotchRequest{},
+		Auth:     HoppscotchAuth{AuthType: "inherit", AuthActive: true},
+		Headers:  []HoppscotchHeader{},
+	}
+}
+
+// hoppscotchHeaders converts headers or query parameters from the form
+// Insomnia stores them in.
+func hoppscotchHeaders(pairs []map[string]string) []HoppscotchHeader {
+	headers := []HoppscotchHeader{}
+	for _, pair := range pairs {
+		headers = append(headers, HoppscotchHeader{
+			Key:    pair["name"],
+			Value:  pair["value"],
+			Active: true,
+		})
+	}
+	return headers
+}
+
+// insomniaVariable matches the Insomnia template tags which output a variable
+// of the environment, such as {{ token }} or {{ _.token }}.
+var insomniaVariable = regexp.MustCompile(`\{\{\s*(?:_\.)?([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// hoppscotchTemplate translates the variables of value, which may hold
+// Insomnia template tags, to Hoppscotch's <<variable>> syntax. It returns
+// false if value holds other tags, such as {% uuid 'v4' %} or variables with
+// filters, which Hoppscotch has no equivalent of.
+func hoppscotchTemplate(value string) (string, bool) {
+	translated := insomniaVariable.ReplaceAllString(value, "<<$1>>")
+	return translated, !strings.Contains(translated, "{{") && !strings.Contains(translated, "{%")
+}
+
+// marshalHoppscotch indents v like json.MarshalIndent, but leaves the angle
+// brackets of Hoppscotch's <<variable>> syntax unescaped.
+func marshalHoppscotch(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thesilentg/proto-to-insomnia/insomnia"
+)
+
+const (
+	archivedFolderID = "request_group_archived"
+	// generatedHashesKey is the field of generated requests holding the
+	// hashes of their editable fields as generated.
+	generatedHashesKey = "generatedHashes"
+)
+
+// editableFields lists the fields of generated requests which are preserved
+// when users edit them.
+var editableFields = map[string][]string{
+	"request":      {"body", "headers", "authentication", "parameters"},
+	"grpc_request": {"body", "metadata"},
+}
+
+// variableFields lists the fields holding variables, whose values are
+// preserved while new variables are added.
+var variableFields = map[string]string{
+	"environment":   "data",
+	"request_group": "environment",
+}
+
+// requestIDPrefixes are the prefixes of the IDs of generated requests, which
+// are archived once their method is removed.
+var requestIDPrefixes = []string{"request-", "grpc_request-"}
+
+// regeneratedIDPrefixes are the prefixes of the IDs of the other generated
+// resources which are removed once they're no longer generated. Environments
+// are kept, since they hold values set by users.
+var regeneratedIDPrefixes = []string{"request_group-", "unit_test_suite-", "unit_test-", "proto_file-", "proto_directory-"}
+
+// mergeExport merges the generated export into the previous export of the
+// same name in the merge directory, if there is one. Resources are matched by
+// ID: the fields users edit keep their edited values, and the requests of
+// removed methods are moved into an Archived folder.
+func (e *insomniaenv) mergeExport(name, workspaceID string, export *insomnia.Export) error {
+	generated, err := toResourceMaps(export.Resources)
+	if err != nil {
+		return err
+	}
+	addGeneratedHashes(generated)
+
+	path := filepath.Join(e.params.merge, filepath.FromSlash(name))
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		export.Resources = resourceList(generated)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var previous struct {
+		ExportFormat int                      `json:"__export_format"`
+		Resources    []map[string]interface{} `json:"resources"`
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&previous); err != nil {
+		return fmt.Errorf("can't merge into %s: %v", path, err)
+	}
+
+	if previous.ExportFormat > export.ExportFormat {
+		export.ExportFormat = previous.ExportFormat
+	}
+	export.Resources = resourceList(mergeResources(workspaceID, previous.Resources, generated))
+	return nil
+}
+
+// mergeResources merges generated resources into previous resources.
+// Generated resources come first, in the order they were generated, followed
+// by the Archived folder, the archived requests and the resources users
+// added.
+func mergeResources(workspaceID string, previous, generated []map[string]interface{}) []map[string]interface{} {
+	previousByID := map[string]map[string]interface{}{}
+	for _, r := range previous {
+		previousByID[stringField(r, "_id")] = r
+	}
+
+	present := map[string]bool{}
+	merged := []map[string]interface{}{}
+	for _, r := range generated {
+		id := stringField(r, "_id")
+		if old, ok := previousByID[id]; ok {
+			r = mergeResource(old, r)
+		}
+		present[id] = true
+		merged = append(merged, r)
+	}
+
+	var folder map[string]interface{}
+	var archived, kept []map[string]interface{}
+	for _, r := range previous {
+		id := stringField(r, "_id")
+		switch {
+		case present[id]:
+		case id == archivedFolderID:
+			folder = r
+		case hasAnyPrefix(id, requestIDPrefixes):
+			r["parentId"] = archivedFolderID
+			archived = append(archived, r)
+		case hasAnyPrefix(id, regeneratedIDPrefixes):
+		default:
+			kept = append(kept, r)
+		}
+	}
+	for _, r := range append(archived, kept...) {
+		present[stringField(r, "_id")] = true
+	}
+	present[archivedFolderID] = true
+	// Resources users added to removed folders are archived too.
+	needFolder := len(archived) > 0
+	for _, r := range kept {
+		if parentID := stringField(r, "parentId"); parentID != "" && !present[parentID] {
+			r["parentId"] = archivedFolderID
+			needFolder = true
+		}
+	}
+
+	if folder == nil && needFolder {
+		folder = map[string]interface{}{
+			"_type":       "request_group",
+			"_id":         archivedFolderID,
+			"parentId":    workspaceID,
+			"name":        "Archived",
+			"description": "Requests of methods which were removed from the proto files.",
+			"environment": map[string]interface{}{},
+		}
+	}
+	if folder != nil {
+		merged = append(merged, folder)
+	}
+	merged = append(merged, archived...)
+	return append(merged, kept...)
+}
+
+// mergeResource returns the generated resource, with the fields of the
+// previous resource which weren't generated, and the values of the editable
+// fields users edited.
+func mergeResource(old, generated map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range old {
+		merged[k] = v
+	}
+	for k, v := range generated {
+		merged[k] = v
+	}
+
+	typ := stringField(generated, "_type")
+	hashes, _ := old[generatedHashesKey].(map[string]interface{})
+	for _, field := range editableFields[typ] {
+		// Without the hash of the value previously generated, any difference
+		// is taken to be an edit.
+		generatedHash, ok := hashes[field].(string)
+		if !ok {
+			generatedHash = hashValue(generated[field])
+		}
+		if hashValue(old[field]) == generatedHash {
+			continue
+		}
+		if v, ok := old[field]; ok {
+			merged[field] = v
+		} else {
+			delete(merged, field)
+		}
+	}
+
+	if field, ok := variableFields[typ]; ok {
+		oldVars, ok := old[field].(map[string]interface{})
+		generatedVars, generatedOK := generated[field].(map[string]interface{})
+		if ok && generatedOK {
+			vars := map[string]interface{}{}
+			for k, v := range generatedVars {
+				vars[k] = v
+			}
+			for k, v := range oldVars {
+				vars[k] = v
+			}
+			merged[field] = vars
+		}
+	}
+	return merged
+}
+
+// addGeneratedHashes records the hashes of the editable fields of generated
+// requests, which tell the next merge whether users edited them.
+func addGeneratedHashes(resources []map[string]interface{}) {
+	for _, r := range resources {
+		fields := editableFields[stringField(r, "_type")]
+		if len(fields) == 0 {
+			continue
+		}
+		hashes := map[string]interface{}{}
+		for _, field := range fields {
+			hashes[field] = hashValue(r[field])
+		}
+		r[generatedHashesKey] = hashes
+	}
+}
+
+// hashValue hashes the JSON encoding of v, in which object keys are sorted.
+func hashValue(v interface{}) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// toResourceMaps converts resources to JSON objects.
+func toResourceMaps(resources []interface{}) ([]map[string]interface{}, error) {
+	b, err := json.Marshal(resources)
+	if err != nil {
+		return nil, err
+	}
+	var maps []map[string]interface{}
+	if err := json.Unmarshal(b, &maps); err != nil {
+		return nil, err
+	}
+	return maps, nil
+}
+
+func resourceList(maps []map[string]interface{}) []interface{} {
+	resources := make([]interface{}, len(maps))
+	for i, r := range maps {
+		resources[i] = r
+	}
+	return resources
+}
+
+func stringField(r map[string]interface{}, field string) string {
+	s, _ := r[field].(string)
+	return s
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mergeV1 = `syntax = "proto3";
+package merge;
+
+service Items {
+  rpc Get(GetRequest) returns (Item);
+  rpc List(ListRequest) returns (Item);
+}
+
+message GetRequest { string id = 1; }
+message ListRequest { int32 page_size = 1; }
+message Item { string id = 1; }
+`
+
+// mergeV2 removes the List method and adds a field to GetRequest.
+const mergeV2 = `syntax = "proto3";
+package merge;
+
+service Items {
+  rpc Get(GetRequest) returns (Item);
+}
+
+message GetRequest { string id = 1; string name = 2; }
+message Item { string id = 1; }
+`
+
+func TestMergeRegeneratesUneditedRequests(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	saveExport(t, dir, generateMerged(t, dir, mergeV1))
+
+	got := requestBody(t, findResource(t, generateMerged(t, dir, mergeV2), "request-Items-Get"))
+	want := requestBody(t, findResource(t, generateMerged(t, "", mergeV2), "request-Items-Get"))
+	if got != want {
+		t.Errorf("got body %s, want the regenerated body %s", got, want)
+	}
+	if !strings.Contains(got, `"name"`) {
+		t.Errorf("body %s lacks the added field", got)
+	}
+}
+
+func TestMergeKeepsEditedRequests(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	export := generateMerged(t, dir, mergeV1)
+	edited := `{"id": "edited"}`
+	findResource(t, export, "request-Items-Get")["body"].(map[string]interface{})["text"] = edited
+	saveExport(t, dir, export)
+
+	if got := requestBody(t, findResource(t, generateMerged(t, dir, mergeV2), "request-Items-Get")); got != edited {
+		t.Errorf("got body %s, want the edited body %s", got, edited)
+	}
+}
+
+func TestMergeArchivesRemovedMethods(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	saveExport(t, dir, generateMerged(t, dir, mergeV1))
+
+	export := generateMerged(t, dir, mergeV2)
+	findResource(t, export, archivedFolderID)
+	if got := stringField(findResource(t, export, "request-Items-List"), "parentId"); got != archivedFolderID {
+		t.Errorf("got removed request in %q, want it archived in %q", got, archivedFolderID)
+	}
+	if got := stringField(findResource(t, export, "request-Items-Get"), "parentId"); got != "request_group-Items" {
+		t.Errorf("got kept request in %q, want it in request_group-Items", got)
+	}
+	saveExport(t, dir, export)
+
+	// Adding the method back moves its request out of the archive.
+	export = generateMerged(t, dir, mergeV1)
+	if got := stringField(findResource(t, export, "request-Items-List"), "parentId"); got != "request_group-Items" {
+		t.Errorf("got re-added request in %q, want it in request_group-Items", got)
+	}
+}
+
+func TestMergeKeepsEnvironmentValues(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	export := generateMerged(t, dir, mergeV1)
+	data := findResource(t, export, "LocalhostHttp")["data"].(map[string]interface{})
+	data["base_url"] = "http://staging:8080"
+	data["user_id"] = "42"
+	saveExport(t, dir, export)
+
+	data = findResource(t, generateMerged(t, dir, mergeV2), "LocalhostHttp")["data"].(map[string]interface{})
+	for k, want := range map[string]string{"base_url": "http://staging:8080", "user_id": "42"} {
+		if got := data[k]; got != want {
+			t.Errorf("got %s %v, want %q", k, got, want)
+		}
+	}
+}
+
+// generateMerged generates the export of a file holding source, merged into
+// the previous export in mergeDir unless it's empty, and returns it as a JSON
+// object.
+func generateMerged(t *testing.T, mergeDir, source string) map[string]interface{} {
+	t.Helper()
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "api.proto"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parameter := ""
+	if mergeDir != "" {
+		parameter = "merge=" + mergeDir
+	}
+	req, err := parseProtoFiles([]string{"api.proto"}, []string{dir}, parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	e := insomniaenv{stderr: &stderr}
+	resp, err := e.Generate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("unexpected diagnostics:\n%s", stderr.String())
+	}
+	for _, file := range resp.File {
+		if file.GetName() == "api-insomnia-env.json" {
+			var export map[string]interface{}
+			if err := json.Unmarshal([]byte(file.GetContent()), &export); err != nil {
+				t.Fatal(err)
+			}
+			return export
+		}
+	}
+	t.Fatal("api-insomnia-env.json wasn't generated")
+	return nil
+}
+
+// saveExport writes export to dir, as users would after editing it in
+// Insomnia.
+func saveExport(t *testing.T, dir string, export map[string]interface{}) {
+	t.Helper()
+	b, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "api-insomnia-env.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func findResource(t *testing.T, export map[string]interface{}, id string) map[string]interface{} {
+	t.Helper()
+	resources, _ := export["resources"].([]interface{})
+	for _, r := range resources {
+		if r, ok := r.(map[string]interface{}); ok && stringField(r, "_id") == id {
+			return r
+		}
+	}
+	t.Fatalf("export has no resource %q", id)
+	return nil
+}
+
+func requestBody(t *testing.T, request map[string]interface{}) string {
+	t.Helper()
+	body, _ := request["body"].(map[string]interface{})
+	return stringField(body, "text")
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/mock"
+	"github.com/thesilentg/proto-to-insomnia/options"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+)
+
+// checkOptions returns an error if an option set on a service or method of
+// files, or on a field of any message, isn't valid.
+func (e *insomniaenv) checkOptions(files []*descriptor.FileDescriptorProto) error {
+	for _, file := range files {
+		for _, service := range file.Service {
+			if auth := options.Service(service).Auth; auth != nil && auth.Type != "" {
+				if err := checkAuthType(auth.Type, e.params.protocol); err != nil {
+					return fmt.Errorf("service %s: %v", service.GetName(), err)
+				}
+			}
+			for _, method := range service.Method {
+				opts := options.Method(method)
+				if opts.Auth != nil && opts.Auth.Type != "" {
+					if err := checkAuthType(opts.Auth.Type, e.params.protocol); err != nil {
+						return fmt.Errorf("method %s.%s: %v", service.GetName(), method.GetName(), err)
+					}
+				}
+				if opts.MockStrategy != "" {
+					if _, err := mock.ParseStrategy(opts.MockStrategy); err != nil {
+						return fmt.Errorf("method %s.%s: %v", service.GetName(), method.GetName(), err)
+					}
+				}
+				for _, example := range opts.Examples {
+					if !json.Valid([]byte(example.Body)) {
+						return fmt.Errorf("method %s.%s: example %q is not valid JSON", service.GetName(), method.GetName(), example.Name)
+					}
+				}
+			}
+		}
+	}
+
+	var checkMessages func(prefix string, messages []*descriptor.DescriptorProto) error
+	checkMessages = func(prefix string, messages []*descriptor.DescriptorProto) error {
+		for _, message := range messages {
+			name := prefix + "." + message.GetName()
+			for _, field := range message.Field {
+				opts := options.Field(field)
+				if opts.Example != "" && !json.Valid([]byte(opts.Example)) {
+					return fmt.Errorf("field %s.%s: example is not valid JSON", name[1:], field.GetName())
+				}
+				if opts.Hint != "" && !mock.KnownHint(opts.Hint) {
+					return fmt.Errorf("field %s.%s: unknown hint %q", name[1:], field.GetName(), opts.Hint)
+				}
+				if opts.Skip && e.presence.Of(field) == presence.Required {
+					return fmt.Errorf("field %s.%s: required fields can't be skipped", name[1:], field.GetName())
+				}
+			}
+			if err := checkMessages(name, message.NestedType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, file := range e.files {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = "." + file.GetPackage()
+		}
+		if err := checkMessages(prefix, file.MessageType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/thesilentg/proto-to-insomnia/mock"
+	"github.com/thesilentg/proto-to-insomnia/options"
+)
+
+const (
+	formatInsomnia   = "insomnia"
+	formatHoppscotch = "hoppscotch"
+
+	protocolTwirp   = "twirp"
+	protocolConnect = "connect"
+	protocolGRPCWeb = "grpc-web"
+	protocolGRPC    = "grpc"
+
+	protobufBodyFile = "file"
+
+	presenceInclude = "include"
+	presenceOmit    = "omit"
+)
+
+type commandLineParams struct {
+	format       string                // Output format, either "insomnia" or "hoppscotch".
+	protocol     string                // Protocol the generated requests use: "twirp", "connect", "grpc-web" or "grpc".
+	protobufBody string                // How application/protobuf request variants carry their body, if generated.
+	pathPrefix   *string               // Path services are mounted under, if not the protocol's default.
+	routes       bool                  // Whether to also write the routes of all generated requests.
+	unitTests    bool                  // Whether to generate unit tests validating each method's response.
+	auth         string                // Authentication of requests without an auth option, if any.
+	apiKeyHeader string                // Header carrying the key of apikey authentication.
+	headers      []*options.Header     // Headers added to every request.
+	merge        string                // Directory of the previous exports to merge generated exports into, if any.
+	strict       bool                  // Whether warnings and errors fail generation instead of being printed.
+	extensions   bool                  // Whether mocks include the extensions of their messages.
+	presence     mock.PresenceStrategy // Whether mocks include the fields with explicit presence.
+	mockStrategy mock.Strategy         // How methods without a mock_strategy option are mocked.
+	repeated     int                   // Number of elements of repeated fields in full mocks, if set.
+	origName     bool                  // Whether mocks key fields by their names in the proto file.
+	enumsAsInts  bool                  // Whether mocks write enum values as numbers.
+	emitDefaults bool                  // Whether mocks include the fields holding their default value.
+	validateJSON bool                  // Whether to check that bodies round-trip through the JSON mapping.
+	include      []*regexp.Regexp      // Services and methods to generate requests for, if not all of them.
+	exclude      []*regexp.Regexp      // Services and methods not to generate requests for.
+	deprecated   string                // Whether deprecated services and methods are included, marked or skipped.
+}
+
+// parseCommandLineParams breaks the comma-separated list of key=value pairs
+// in the parameter (a member of the request protobuf) into a key/value map.
+// It then sets command line parameter mappings defined by those entries.
+func parseCommandLineParams(parameter string) (*commandLineParams, error) {
+	ps := make(map[string]string)
+	for _, p := range strings.Split(parameter, ",") {
+		if p == "" {
+			continue
+		}
+		i := strings.Index(p, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid parameter %q: expected format of parameter to be k=v", p)
+		}
+		k := p[0:i]
+		v := p[i+1:]
+		if v == "" {
+			return nil, fmt.Errorf("invalid parameter %q: expected format of parameter to be k=v", k)
+		}
+		ps[k] = v
+	}
+
+	clp := &commandLineParams{
+		format:       formatInsomnia,
+		protocol:     protocolTwirp,
+		emitDefaults: true,
+		deprecated:   deprecatedInclude,
+	}
+	for k, v := range ps {
+		switch k {
+		case "format":
+			if v != formatInsomnia && v != formatHoppscotch {
+				return nil, fmt.Errorf("format does not support %q", v)
+			}
+			clp.format = v
+		case "protobuf_body":
+			if v != protobufBodyFile {
+				// Insomnia renders template tags as text, so bodies decoded
+				// from base64 by its base64 tag lose every byte above 0x7f.
+				return nil, fmt.Errorf("protobuf_body does not support %q: binary bodies can only be sent from files", v)
+			}
+			clp.protobufBody = v
+		case "path_prefix":
+			prefix := normalizePathPrefix(v)
+			clp.pathPrefix = &prefix
+		case "routes":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for routes: %v", v, err)
+			}
+			clp.routes = b
+		case "unit_tests":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for unit_tests: %v", v, err)
+			}
+			clp.unitTests = b
+		case "auth":
+			clp.auth = v
+		case "api_key_header":
+			clp.apiKeyHeader = v
+		case "headers":
+			headers, err := readHeadersFile(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid headers file: %v", err)
+			}
+			clp.headers = headers
+		case "merge":
+			clp.merge = v
+		case "strict":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for strict: %v", v, err)
+			}
+			clp.strict = b
+		case "extensions":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for extensions: %v", v, err)
+			}
+			clp.extensions = b
+		case "presence":
+			switch v {
+			case presenceInclude:
+				clp.presence = mock.IncludePresence
+			case presenceOmit:
+				clp.presence = mock.OmitPresence
+			default:
+				return nil, fmt.Errorf("presence does not support %q", v)
+			}
+		case "mock_strategy":
+			strategy, err := mock.ParseStrategy(v)
+			if err != nil {
+				return nil, err
+			}
+			clp.mockStrategy = strategy
+		case "repeated_count":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid value %q for repeated_count: expected a positive integer", v)
+			}
+			clp.repeated = n
+		case "orig_name":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for orig_name: %v", v, err)
+			}
+			clp.origName = b
+		case "enums_as_ints":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for enums_as_ints: %v", v, err)
+			}
+			clp.enumsAsInts = b
+		case "emit_defaults":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for emit_defaults: %v", v, err)
+			}
+			clp.emitDefaults = b
+		case "validate_json":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for validate_json: %v", v, err)
+			}
+			clp.validateJSON = b
+		case "include", "exclude":
+			filters, err := parseFilters(k, v)
+			if err != nil {
+				return nil, err
+			}
+			if k == "include" {
+				clp.include = filters
+			} else {
+				clp.exclude = filters
+			}
+		case "deprecated":
+			if v != deprecatedInclude && v != deprecatedMark && v != deprecatedSkip {
+				return nil, fmt.Errorf("deprecated does not support %q", v)
+			}
+			clp.deprecated = v
+		case "protocol":
+			if v != protocolGRPC && newHTTPProtocol(v) == nil {
+				return nil, fmt.Errorf("protocol does not support %q", v)
+			}
+			clp.protocol = v
+		default:
+			return nil, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+
+	if clp.protocol == protocolGRPC && clp.format != formatInsomnia {
+		return nil, fmt.Errorf("protocol %q is only supported by format %q", clp.protocol, formatInsomnia)
+	}
+	if clp.protobufBody != "" && clp.format != formatInsomnia {
+		return nil, fmt.Errorf("protobuf_body is only supported by format %q", formatInsomnia)
+	}
+	if clp.protobufBody != "" {
+		if p := newHTTPProtocol(clp.protocol); p == nil || p.protobufContentType() == "" {
+			return nil, fmt.Errorf("protobuf_body is not supported by protocol %q", clp.protocol)
+		}
+	}
+	if clp.auth != "" {
+		if err := checkAuthType(clp.auth, clp.protocol); err != nil {
+			return nil, err
+		}
+	}
+	if clp.unitTests {
+		if clp.format != formatInsomnia {
+			return nil, fmt.Errorf("unit_tests is only supported by format %q", formatInsomnia)
+		}
+		if clp.protocol != protocolTwirp && clp.protocol != protocolConnect {
+			return nil, fmt.Errorf("unit_tests is not supported by protocol %q", clp.protocol)
+		}
+	}
+	if clp.merge != "" && clp.format != formatInsomnia {
+		return nil, fmt.Errorf("merge is only supported by format %q", formatInsomnia)
+	}
+	return clp, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommandLineParamsErrors(t *testing.T) {
+	for _, c := range []struct {
+		parameter string
+		want      string
+	}{
+		{"protobuf_body=base64", `protobuf_body does not support "base64"`},
+		{"protobuf_body=file,format=hoppscotch", `protobuf_body is only supported by format "insomnia"`},
+		{"protobuf_body=file,protocol=grpc-web", `protobuf_body is not supported by protocol "grpc-web"`},
+		{"deprecated=hide", `deprecated does not support "hide"`},
+		{"exclude=/(/", `invalid pattern "/(/" for exclude`},
+	} {
+		_, err := parseCommandLineParams(c.parameter)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want it to contain %q", c.parameter, err, c.want)
+		}
+	}
+}
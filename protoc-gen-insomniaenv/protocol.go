@@ -0,0 +1,257 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/insomnia"
+	"github.com/twitchtv/protogen/stringutils"
+)
+
+// httpProtocol describes an RPC protocol carried over plain HTTP requests,
+// which Insomnia and Hoppscotch can send without any protocol support of their
+// own.
+type httpProtocol interface {
+	// defaultPathPrefix returns the path services are mounted under unless
+	// configured otherwise. It has no trailing slash, and is empty for services
+	// mounted at the root.
+	defaultPathPrefix() string
+	// pathPrefix returns the base path for all methods handled by service when
+	// it's mounted under prefix. It includes a trailing slash.
+	pathPrefix(prefix string, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string
+	// methodPath returns the path of method, relative to its service's
+	// pathPrefix.
+	methodPath(method *descriptor.MethodDescriptorProto) string
+	// newCall returns the HTTP request which sends messages, mocks of
+	// method's input encoded as JSON. There is a single message unless the
+	// client streams them.
+	newCall(e *insomniaenv, method *descriptor.MethodDescriptorProto, messages []string) (httpCall, error)
+	// protobufContentType returns the content type of requests carrying a
+	// binary protobuf body, or "" if the protocol has no such variant.
+	protobufContentType() string
+	// protobufHeaders returns the headers of requests carrying a binary
+	// protobuf body, starting with their Content-Type.
+	protobufHeaders() []map[string]string
+}
+
+// httpCall describes a single HTTP request to a method.
+type httpCall struct {
+	method     string
+	parameters []map[string]string
+	headers    []map[string]string
+	body       insomnia.RequestBody
+	binaryBody []byte // Body which can't be held as text, sent from a file instead of body's text.
+}
+
+// nameValue returns a header or query parameter as Insomnia stores them.
+func nameValue(name, value string) map[string]string {
+	return map[string]string{
+		"name":  name,
+		"value": value,
+	}
+}
+
+// newHTTPProtocol returns the protocol named name, or nil if name isn't an
+// HTTP based protocol.
+func newHTTPProtocol(name string) httpProtocol {
+	switch name {
+	case protocolTwirp:
+		return twirpProtocol{}
+	case protocolConnect:
+		return connectProtocol{}
+	case protocolGRPCWeb:
+		return grpcWebProtocol{}
+	}
+	return nil
+}
+
+// twirpProtocol sends JSON requests to Twirp services. Paths are built the
+// same way protoc-gen-twirp builds the routes of generated servers.
+type twirpProtocol struct{}
+
+func (twirpProtocol) defaultPathPrefix() string {
+	return "/twirp"
+}
+
+func (twirpProtocol) pathPrefix(prefix string, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	return pathPrefix(prefix, file, service)
+}
+
+func (twirpProtocol) methodPath(method *descriptor.MethodDescriptorProto) string {
+	return stringutils.CamelCase(method.GetName())
+}
+
+func (twirpProtocol) newCall(e *insomniaenv, method *descriptor.MethodDescriptorProto, messages []string) (httpCall, error) {
+	body := messages[0]
+	return httpCall{
+		method:  "POST",
+		headers: []map[string]string{nameValue("Content-Type", "application/json")},
+		body: insomnia.RequestBody{
+			MimeType: "application/json",
+			Text:     body,
+		},
+	}, nil
+}
+
+func (twirpProtocol) protobufContentType() string {
+	return "application/protobuf"
+}
+
+func (p twirpProtocol) protobufHeaders() []map[string]string {
+	return []map[string]string{nameValue("Content-Type", p.protobufContentType())}
+}
+
+// connectProtocol sends JSON requests to Connect services. Methods without
+// side effects are called with GET requests, which Connect allows to be
+// cached. Streaming methods are sent their messages in the envelopes of
+// Connect's streaming protocol.
+type connectProtocol struct{}
+
+func (connectProtocol) defaultPathPrefix() string {
+	return ""
+}
+
+func (connectProtocol) pathPrefix(prefix string, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	return fmt.Sprintf("%s/%s/", prefix, protoServiceName(file, service))
+}
+
+func (connectProtocol) methodPath(method *descriptor.MethodDescriptorProto) string {
+	return method.GetName()
+}
+
+func (connectProtocol) newCall(e *insomniaenv, method *descriptor.MethodDescriptorProto, messages []string) (httpCall, error) {
+	if isStreaming(method) {
+		return connectStreamCall(messages)
+	}
+	body := messages[0]
+	if method.GetOptions().GetIdempotencyLevel() == descriptor.MethodOptions_NO_SIDE_EFFECTS {
+		var message bytes.Buffer
+		if err := json.Compact(&message, []byte(body)); err != nil {
+			return httpCall{}, err
+		}
+		return httpCall{
+			method: "GET",
+			parameters: []map[string]string{
+				nameValue("connect", "v1"),
+				nameValue("encoding", "json"),
+				nameValue("message", message.String()),
+			},
+			headers: []map[string]string{},
+		}, nil
+	}
+	return httpCall{
+		method: "POST",
+		headers: []map[string]string{
+			nameValue("Content-Type", "application/json"),
+			nameValue("Connect-Protocol-Version", "1"),
+		},
+		body: insomnia.RequestBody{
+			MimeType: "application/json",
+			Text:     body,
+		},
+	}, nil
+}
+
+func (connectProtocol) protobufContentType() string {
+	return "application/proto"
+}
+
+func (p connectProtocol) protobufHeaders() []map[string]string {
+	return []map[string]string{
+		nameValue("Content-Type", p.protobufContentType()),
+		nameValue("Connect-Protocol-Version", "1"),
+	}
+}
+
+// connectStreamCall returns the request to a Connect streaming method which
+// sends messages, each in an envelope. Envelopes are framed the same way as
+// gRPC messages, in binary, so the body is sent from a file.
+func connectStreamCall(messages []string) (httpCall, error) {
+	var body []byte
+	for _, message := range messages {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(message)); err != nil {
+			return httpCall{}, err
+		}
+		body = append(body, grpcFrame(compact.Bytes())...)
+	}
+	return httpCall{
+		method:  "POST",
+		headers: []map[string]string{nameValue("Content-Type", "application/connect+json")},
+		body: insomnia.RequestBody{
+			MimeType: "application/connect+json",
+		},
+		binaryBody: body,
+	}, nil
+}
+
+// grpcWebProtocol sends requests to gRPC-Web services. gRPC-Web frames every
+// message in binary, so bodies are sent using the base64 "text" variant of
+// the protocol, which Insomnia can hold in a text body. Server streaming
+// methods are called the same way as unary methods.
+type grpcWebProtocol struct{}
+
+func (grpcWebProtocol) defaultPathPrefix() string {
+	return ""
+}
+
+func (grpcWebProtocol) pathPrefix(prefix string, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	return fmt.Sprintf("%s/%s/", prefix, protoServiceName(file, service))
+}
+
+func (grpcWebProtocol) methodPath(method *descriptor.MethodDescriptorProto) string {
+	return method.GetName()
+}
+
+func (grpcWebProtocol) newCall(e *insomniaenv, method *descriptor.MethodDescriptorProto, messages []string) (httpCall, error) {
+	msg := e.registry.MessageDefinition(method.GetInputType())
+	encoded, err := e.mocks.Encode(msg, messages[0])
+	if err != nil {
+		return httpCall{}, err
+	}
+	return httpCall{
+		method: "POST",
+		headers: []map[string]string{
+			nameValue("Content-Type", "application/grpc-web-text"),
+			nameValue("Accept", "application/grpc-web-text"),
+			nameValue("X-Grpc-Web", "1"),
+		},
+		body: insomnia.RequestBody{
+			MimeType: "application/grpc-web-text",
+			Text:     base64.StdEncoding.EncodeToString(grpcFrame(encoded)),
+		},
+	}, nil
+}
+
+func (grpcWebProtocol) protobufContentType() string {
+	return ""
+}
+
+func (grpcWebProtocol) protobufHeaders() []map[string]string {
+	return nil
+}
+
+// grpcFrame prefixes msg with the flags byte and big-endian length used to
+// frame gRPC messages.
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
@@ -0,0 +1,340 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+)
+
+// protoPrinter renders a FileDescriptorProto back into .proto source. protoc
+// doesn't hand plugins the original source, but Insomnia needs it to build
+// gRPC requests. The output is semantically equivalent to the input but not
+// identical: comments and most options are dropped, and all type references
+// are fully-qualified. Files using editions are printed as proto2 files, with
+// labels standing for the presence of their fields, since the libraries
+// Insomnia loads them with don't support editions.
+type protoPrinter struct {
+	file     *descriptor.FileDescriptorProto
+	presence *presence.Index
+	out      strings.Builder
+	indent   int
+}
+
+func printProtoFile(file *descriptor.FileDescriptorProto, presence *presence.Index) string {
+	p := &protoPrinter{file: file, presence: presence}
+	p.printFile()
+	return p.out.String()
+}
+
+func (p *protoPrinter) P(args ...interface{}) {
+	if len(args) > 0 {
+		p.out.WriteString(strings.Repeat("  ", p.indent))
+	}
+	for _, arg := range args {
+		fmt.Fprint(&p.out, arg)
+	}
+	p.out.WriteByte('\n')
+}
+
+func (p *protoPrinter) proto3() bool {
+	return p.file.GetSyntax() == "proto3"
+}
+
+func (p *protoPrinter) printFile() {
+	syntax := p.file.GetSyntax()
+	if syntax == "" || presence.Editions(p.file) {
+		syntax = "proto2"
+	}
+	p.P(`syntax = "`, syntax, `";`)
+	p.P()
+	if pkg := p.file.GetPackage(); pkg != "" {
+		p.P(`package `, pkg, `;`)
+		p.P()
+	}
+
+	if len(p.file.Dependency) > 0 {
+		public := map[int32]bool{}
+		for _, i := range p.file.PublicDependency {
+			public[i] = true
+		}
+		weak := map[int32]bool{}
+		for _, i := range p.file.WeakDependency {
+			weak[i] = true
+		}
+		for i, dep := range p.file.Dependency {
+			switch {
+			case public[int32(i)]:
+				p.P(`import public "`, dep, `";`)
+			case weak[int32(i)]:
+				p.P(`import weak "`, dep, `";`)
+			default:
+				p.P(`import "`, dep, `";`)
+			}
+		}
+		p.P()
+	}
+
+	for _, enum := range p.file.EnumType {
+		p.printEnum(enum)
+		p.P()
+	}
+	for _, msg := range p.file.MessageType {
+		p.printMessage(msg)
+		p.P()
+	}
+	p.printExtensions(p.file.Extension)
+	for _, service := range p.file.Service {
+		p.printService(service)
+		p.P()
+	}
+}
+
+func (p *protoPrinter) printEnum(enum *descriptor.EnumDescriptorProto) {
+	p.P(`enum `, enum.GetName(), ` {`)
+	p.indent++
+	if enum.GetOptions().GetAllowAlias() {
+		p.P(`option allow_alias = true;`)
+	}
+	for _, value := range enum.Value {
+		p.P(value.GetName(), ` = `, value.GetNumber(), `;`)
+	}
+	p.printReservedNames(enum.ReservedName)
+	for _, r := range enum.ReservedRange {
+		// Enum reserved ranges are inclusive, unlike message ones.
+		p.P(`reserved `, rangeString(r.GetStart(), r.GetEnd()+1), `;`)
+	}
+	p.indent--
+	p.P(`}`)
+}
+
+func (p *protoPrinter) printMessage(msg *descriptor.DescriptorProto) {
+	p.P(`message `, msg.GetName(), ` {`)
+	p.indent++
+
+	mapEntries := map[string]*descriptor.DescriptorProto{}
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			mapEntries[nested.GetName()] = nested
+		}
+	}
+
+	printedOneofs := map[int32]bool{}
+	for _, field := range msg.Field {
+		// The oneofs of proto3 optional fields are implied by their label.
+		if field.OneofIndex == nil || presence.Proto3Optional(field) {
+			p.printField(field, mapEntries)
+			continue
+		}
+		idx := field.GetOneofIndex()
+		if printedOneofs[idx] {
+			continue
+		}
+		printedOneofs[idx] = true
+		p.P(`oneof `, msg.OneofDecl[idx].GetName(), ` {`)
+		p.indent++
+		for _, member := range msg.Field {
+			if member.OneofIndex != nil && member.GetOneofIndex() == idx {
+				p.printField(member, mapEntries)
+			}
+		}
+		p.indent--
+		p.P(`}`)
+	}
+
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			continue
+		}
+		p.printMessage(nested)
+	}
+	for _, enum := range msg.EnumType {
+		p.printEnum(enum)
+	}
+	p.printExtensions(msg.Extension)
+
+	for _, r := range msg.ExtensionRange {
+		p.P(`extensions `, rangeString(r.GetStart(), r.GetEnd()), `;`)
+	}
+	for _, r := range msg.ReservedRange {
+		p.P(`reserved `, rangeString(r.GetStart(), r.GetEnd()), `;`)
+	}
+	p.printReservedNames(msg.ReservedName)
+
+	p.indent--
+	p.P(`}`)
+}
+
+func (p *protoPrinter) printReservedNames(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	p.P(`reserved `, strings.Join(quoted, ", "), `;`)
+}
+
+// rangeString formats the half-open range [start, end) as it's written in a
+// reserved or extensions statement.
+func rangeString(start, end int32) string {
+	switch {
+	case end-1 == start:
+		return strconv.Itoa(int(start))
+	case end >= 536870912:
+		return fmt.Sprintf("%d to max", start)
+	}
+	return fmt.Sprintf("%d to %d", start, end-1)
+}
+
+func (p *protoPrinter) printExtensions(extensions []*descriptor.FieldDescriptorProto) {
+	// Group the extensions by the message they extend, preserving the order in
+	// which each extendee first appears.
+	var extendees []string
+	byExtendee := map[string][]*descriptor.FieldDescriptorProto{}
+	for _, ext := range extensions {
+		if _, ok := byExtendee[ext.GetExtendee()]; !ok {
+			extendees = append(extendees, ext.GetExtendee())
+		}
+		byExtendee[ext.GetExtendee()] = append(byExtendee[ext.GetExtendee()], ext)
+	}
+	for _, extendee := range extendees {
+		p.P(`extend `, extendee, ` {`)
+		p.indent++
+		for _, ext := range byExtendee[extendee] {
+			p.printField(ext, nil)
+		}
+		p.indent--
+		p.P(`}`)
+		p.P()
+	}
+}
+
+func (p *protoPrinter) printField(field *descriptor.FieldDescriptorProto, mapEntries map[string]*descriptor.DescriptorProto) {
+	typeName := fieldTypeName(field)
+	if entry := mapEntryFor(field, mapEntries); entry != nil {
+		p.P(`map<`, fieldTypeName(entry.Field[0]), `, `, fieldTypeName(entry.Field[1]), `> `, field.GetName(), ` = `, field.GetNumber(), `;`)
+		return
+	}
+
+	label := ""
+	switch {
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		label = "repeated "
+	case p.presence.Of(field) == presence.Required:
+		label = "required "
+	case presence.Proto3Optional(field):
+		label = "optional "
+	case !p.proto3() && field.OneofIndex == nil:
+		label = "optional "
+	}
+
+	var options []string
+	if field.JsonName != nil && field.GetJsonName() != defaultJSONName(field.GetName()) {
+		options = append(options, fmt.Sprintf("json_name = %q", field.GetJsonName()))
+	}
+	if field.DefaultValue != nil {
+		options = append(options, "default = "+defaultValueLiteral(field))
+	}
+	if opts := field.GetOptions(); opts != nil && opts.Packed != nil {
+		options = append(options, fmt.Sprintf("packed = %t", opts.GetPacked()))
+	}
+	if field.GetOptions().GetDeprecated() {
+		options = append(options, "deprecated = true")
+	}
+	suffix := ""
+	if len(options) > 0 {
+		suffix = " [" + strings.Join(options, ", ") + "]"
+	}
+
+	p.P(label, typeName, ` `, field.GetName(), ` = `, field.GetNumber(), suffix, `;`)
+}
+
+// defaultJSONName returns the JSON name protoc gives a field named name
+// without a json_name option: its name in lower camel case.
+func defaultJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func mapEntryFor(field *descriptor.FieldDescriptorProto, mapEntries map[string]*descriptor.DescriptorProto) *descriptor.DescriptorProto {
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return nil
+	}
+	name := field.GetTypeName()
+	return mapEntries[name[strings.LastIndex(name, ".")+1:]]
+}
+
+func fieldTypeName(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return field.GetTypeName()
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		// Groups are printed as plain message fields, which keeps their JSON
+		// representation but changes their wire format: Insomnia's gRPC
+		// requests encode the group as a length-delimited message, which
+		// servers expecting a group can't read.
+		return field.GetTypeName()
+	}
+	// TYPE_INT32 -> int32
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+func defaultValueLiteral(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return strconv.Quote(field.GetDefaultValue())
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		// protoc stores bytes defaults C-escaped, which is also valid in a
+		// .proto string literal.
+		return `"` + field.GetDefaultValue() + `"`
+	}
+	return field.GetDefaultValue()
+}
+
+func (p *protoPrinter) printService(service *descriptor.ServiceDescriptorProto) {
+	p.P(`service `, service.GetName(), ` {`)
+	p.indent++
+	for _, method := range service.Method {
+		input := method.GetInputType()
+		if method.GetClientStreaming() {
+			input = "stream " + input
+		}
+		output := method.GetOutputType()
+		if method.GetServerStreaming() {
+			output = "stream " + output
+		}
+		p.P(`rpc `, method.GetName(), `(`, input, `) returns (`, output, `);`)
+	}
+	p.indent--
+	p.P(`}`)
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/protoparse"
+)
+
+// TestProtoFileJSONNames checks that the proto files embedded for gRPC
+// requests declare the same JSON names as the files they're printed from, so
+// that the bodies Insomnia sends are read the same way.
+func TestProtoFileJSONNames(t *testing.T) {
+	parser := protoparse.Parser{ImportPaths: []string{filepath.Join("testdata", "protos")}}
+	originals, err := parser.ParseFiles("imports.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := generateFixtures(t, []string{"imports.proto"}, "protocol=grpc")
+
+	dir, err := ioutil.TempDir("", "protofiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var export struct {
+		Resources []struct {
+			Type      string `json:"_type"`
+			ID        string `json:"_id"`
+			ProtoText string `json:"protoText"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal([]byte(resp.File[0].GetContent()), &export); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range export.Resources {
+		if r.Type != "proto_file" {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(r.ID, "proto_file-")))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(r.ProtoText), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	printed, err := protoparse.Parser{ImportPaths: []string{dir}}.ParseFiles("imports.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonNames := func(files []*descriptor.FileDescriptorProto) map[string]string {
+		names := map[string]string{}
+		for _, file := range files {
+			for _, msg := range file.MessageType {
+				for _, field := range msg.Field {
+					names[file.GetPackage()+"."+msg.GetName()+"."+field.GetName()] = field.GetJsonName()
+				}
+			}
+		}
+		return names
+	}
+	want, got := jsonNames(originals), jsonNames(printed)
+	if want["fixtures.imports.ListInvoicesRequest.customer_id"] != "customer" {
+		t.Fatalf("fixture lost its json_name option: %v", want)
+	}
+	for name, jsonName := range want {
+		if got[name] != jsonName {
+			t.Errorf("%s: got JSON name %q, want %q", name, got[name], jsonName)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/thesilentg/proto-to-insomnia/options"
+	"github.com/twitchtv/protogen/stringutils"
+)
+
+// route describes where the requests for a method are sent. Every generated
+// URL, path and request group variable is derived from a route, so that they
+// all agree with each other.
+type route struct {
+	// variable is the name of the request group environment variable holding
+	// the URL of the service.
+	variable string
+	// servicePath is the base path for all methods handled by the service. It
+	// includes a trailing slash.
+	servicePath string
+	// methodPath is the path of the method, relative to servicePath.
+	methodPath string
+}
+
+// path returns the complete path for requests to the method.
+func (r route) path() string {
+	return r.servicePath + r.methodPath
+}
+
+// url returns the URL of the method as an Insomnia template, relative to the
+// request group variable.
+func (r route) url() string {
+	return fmt.Sprintf("{{%s}}%s", r.variable, r.methodPath)
+}
+
+// serviceRoute returns the route shared by all of the methods of service.
+// Its methodPath is empty.
+func (e *insomniaenv) serviceRoute(protocol httpProtocol, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) route {
+	prefix := protocol.defaultPathPrefix()
+	if e.params.pathPrefix != nil {
+		prefix = *e.params.pathPrefix
+	}
+	if opts := options.Service(service); opts.PathPrefix != "" {
+		prefix = normalizePathPrefix(opts.PathPrefix)
+	}
+	return route{
+		variable:    serviceName(service),
+		servicePath: protocol.pathPrefix(prefix, file, service),
+	}
+}
+
+// methodRoute returns the route of method.
+func (e *insomniaenv) methodRoute(protocol httpProtocol, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) route {
+	r := e.serviceRoute(protocol, file, service)
+	r.methodPath = protocol.methodPath(method)
+	return r
+}
+
+// generateRoutes returns a file listing the HTTP method and path of every
+// request generated for file, one per line. For Twirp, the paths match the
+// cases of the ServeHTTP switch in the server protoc-gen-twirp generates, which
+// is how scripts/check-twirp-routes.sh verifies them.
+func (e *insomniaenv) generateRoutes(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if !e.fileIncluded(file) {
+		return nil
+	}
+
+	var lines []string
+	visitService := func(service *descriptor.ServiceDescriptorProto) {}
+	visitMethod := func(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto, mocks methodMocks) {
+		protocol := newHTTPProtocol(e.params.protocol)
+		if protocol == nil {
+			// gRPC requests are always HTTP/2 POSTs to the method name.
+			lines = append(lines, "POST "+grpcMethodName(file, service, method))
+			return
+		}
+		call, err := protocol.newCall(e, method, mocks.requestMessages())
+		if err != nil {
+			e.diags.Errorf(method, "method %s: request can't be generated: %v", methodFullName(file, service, method), err)
+			return
+		}
+		lines = append(lines, call.method+" "+e.methodRoute(protocol, file, service, method).path())
+	}
+	e.walkMethods(file, visitService, visitMethod)
+
+	fileWithoutPath := strings.TrimSuffix(file.GetName(), filepath.Ext(file.GetName()))
+	return &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(fmt.Sprintf("%s-routes.txt", fileWithoutPath)),
+		Content: proto.String(strings.Join(lines, "\n") + "\n"),
+	}
+}
+
+// normalizePathPrefix returns prefix with a leading slash and without a
+// trailing one, so that "/" and "" both mount services at the root.
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// baseURLVariable returns the environment variable holding the base URL of
+// service's requests.
+func baseURLVariable(service *descriptor.ServiceDescriptorProto) string {
+	if v := options.Service(service).BaseUrlVariable; v != "" {
+		return v
+	}
+	return "base_url"
+}
+
+// fileBaseURLVariables returns the environment variables other than base_url
+// holding the base URLs of the services generated for file, sorted by name.
+func (e *insomniaenv) fileBaseURLVariables(file *descriptor.FileDescriptorProto) []string {
+	seen := map[string]bool{"base_url": true}
+	variables := []string{}
+	for _, service := range file.Service {
+		if !e.serviceIncluded(file, service) {
+			continue
+		}
+		if v := baseURLVariable(service); !seen[v] {
+			seen[v] = true
+			variables = append(variables, v)
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// serviceFolder returns the name of the folder holding service's requests.
+func serviceFolder(service *descriptor.ServiceDescriptorProto) string {
+	if folder := options.Service(service).Folder; folder != "" {
+		return folder
+	}
+	return service.GetName()
+}
+
+func pkgName(file *descriptor.FileDescriptorProto) string {
+	return file.GetPackage()
+}
+
+func serviceName(service *descriptor.ServiceDescriptorProto) string {
+	return stringutils.CamelCase(service.GetName())
+}
+
+func fullServiceName(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	name := serviceName(service)
+	if pkg := pkgName(file); pkg != "" {
+		name = pkg + "." + name
+	}
+	return name
+}
+
+// protoServiceName returns the fully-qualified protobuf name of service,
+// without a leading dot (for example "twitch.example.Haberdasher"). Unlike
+// fullServiceName, the service name is used as written in the proto file.
+func protoServiceName(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	name := service.GetName()
+	if pkg := pkgName(file); pkg != "" {
+		name = pkg + "." + name
+	}
+	return name
+}
+
+// pathPrefix returns the base path for all methods handled by a particular
+// Twirp service mounted under prefix. It includes a trailing slash. (for
+// example "/twirp/twitch.example.Haberdasher/").
+func pathPrefix(prefix string, file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	return fmt.Sprintf("%s/%s/", prefix, fullServiceName(file, service))
+}
+
+// grpcMethodName returns the full method name used to route gRPC requests to
+// method (for example "/twitch.example.Haberdasher/MakeHat").
+func grpcMethodName(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	return fmt.Sprintf("/%s/%s", protoServiceName(file, service), method.GetName())
+}
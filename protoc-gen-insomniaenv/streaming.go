@@ -0,0 +1,83 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/mock"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// streamLength is the number of messages mocked for each stream of a
+// streaming method.
+const streamLength = 3
+
+// isStreaming reports whether the client or the server of method streams
+// messages.
+func isStreaming(method *descriptor.MethodDescriptorProto) bool {
+	return method.GetClientStreaming() || method.GetServerStreaming()
+}
+
+// streamingKind names which sides of method stream messages, as diagnostics
+// refer to it.
+func streamingKind(method *descriptor.MethodDescriptorProto) string {
+	switch {
+	case method.GetClientStreaming() && method.GetServerStreaming():
+		return "bidirectional streaming"
+	case method.GetClientStreaming():
+		return "client streaming"
+	case method.GetServerStreaming():
+		return "server streaming"
+	}
+	return "unary"
+}
+
+// streamingSupported reports whether requests of the protocol and format
+// can call method. Twirp has no streaming methods. Connect streams messages
+// in binary envelopes, which Insomnia sends from files but Hoppscotch can't
+// send, and can't stream both ways over HTTP/1.1. gRPC-Web only streams responses.
+// Insomnia's gRPC requests support every kind of streaming.
+func (e *insomniaenv) streamingSupported(method *descriptor.MethodDescriptorProto) bool {
+	if !isStreaming(method) {
+		return true
+	}
+	switch e.params.protocol {
+	case protocolGRPC:
+		return true
+	case protocolConnect:
+		return e.params.format == formatInsomnia && !(method.GetClientStreaming() && method.GetServerStreaming())
+	case protocolGRPCWeb:
+		return !method.GetClientStreaming()
+	}
+	return false
+}
+
+// streamMocks returns a stream of streamLength mocks of msg, starting with
+// first.
+func (e *insomniaenv) streamMocks(msg *typemap.MessageDefinition, strategy mock.Strategy, first string) []string {
+	messages := []string{first}
+	for len(messages) < streamLength {
+		messages = append(messages, e.mocks.MessageUsing(msg, strategy))
+	}
+	return messages
+}
+
+// requestMessages returns the messages method's request sends: the request
+// stream of client streaming methods, and otherwise the request body alone.
+func (mocks methodMocks) requestMessages() []string {
+	if len(mocks.requests) > 0 {
+		return mocks.requests
+	}
+	return []string{mocks.request}
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestConnectStreamEnvelopes checks that Connect streams frame each message
+// with its length, including messages long enough and characters wide
+// enough to need bytes which aren't ASCII.
+func TestConnectStreamEnvelopes(t *testing.T) {
+	messages := []string{
+		`{"text": "` + strings.Repeat("é", 100) + `"}`,
+		`{"id": "short"}`,
+		`{"text": "` + strings.Repeat("x", 300) + `"}`,
+	}
+	call, err := connectStreamCall(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.body.Text != "" {
+		t.Errorf("got text body %q, want the body sent from a file", call.body.Text)
+	}
+
+	b := call.binaryBody
+	for i, message := range messages {
+		if len(b) < 5 {
+			t.Fatalf("envelope %d is truncated", i)
+		}
+		if b[0] != 0 {
+			t.Errorf("envelope %d: got flags %#x, want 0", i, b[0])
+		}
+		n := int(binary.BigEndian.Uint32(b[1:5]))
+		if len(b) < 5+n {
+			t.Fatalf("envelope %d: length %d overruns the body", i, n)
+		}
+		var got, want interface{}
+		if err := json.Unmarshal(b[5:5+n], &got); err != nil {
+			t.Fatalf("envelope %d: %v", i, err)
+		}
+		if err := json.Unmarshal([]byte(message), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := mustMarshal(t, got), mustMarshal(t, want); got != want {
+			t.Errorf("envelope %d: got %s, want %s", i, got, want)
+		}
+		b = b[5+n:]
+	}
+	if len(b) > 0 {
+		t.Errorf("%d bytes follow the last envelope", len(b))
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
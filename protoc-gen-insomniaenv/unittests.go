@@ -0,0 +1,271 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/insomnia"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+	"github.com/twitchtv/protogen/typemap"
+)
+
+// generateUnitTests returns a unit test suite per service, holding a test per
+// method. Each test sends the method's request and checks that the response
+// succeeded and conforms to the method's output message.
+func (e *insomniaenv) generateUnitTests(workspaceID string, file *descriptor.FileDescriptorProto) []interface{} {
+	resources := []interface{}{}
+	for _, service := range file.Service {
+		if !e.serviceIncluded(file, service) {
+			continue
+		}
+		suiteID := fmt.Sprintf("unit_test_suite-%s", service.GetName())
+		resources = append(resources, insomnia.UnitTestSuite{
+			Resource: insomnia.Resource{
+				Type:     "unit_test_suite",
+				ID:       suiteID,
+				ParentID: &workspaceID,
+				Name:     service.GetName(),
+			},
+		})
+		for _, method := range service.Method {
+			// Streamed responses aren't a single JSON message the test can
+			// check.
+			if !e.methodIncluded(file, service, method) || isStreaming(method) {
+				continue
+			}
+			msg := e.registry.MessageDefinition(method.GetOutputType())
+			if msg == nil {
+				e.diags.Warnf(method, "method %s: output type %s could not be found, so it has no unit test", methodFullName(file, service, method), method.GetOutputType())
+				continue
+			}
+			schema, err := json.Marshal(e.responseSchema(msg))
+			if err != nil {
+				e.diags.Errorf(method, "method %s: unit test can't be generated: %v", methodFullName(file, service, method), err)
+				continue
+			}
+			resources = append(resources, insomnia.UnitTest{
+				Resource: insomnia.Resource{
+					Type:     "unit_test",
+					ID:       fmt.Sprintf("unit_test-%s-%s", service.GetName(), method.GetName()),
+					ParentID: &suiteID,
+					Name:     fmt.Sprintf("%s returns a valid %s", method.GetName(), msg.Descriptor.GetName()),
+				},
+				Code:      fmt.Sprintf(unitTestCode, schema),
+				RequestID: requestID(service, method),
+			})
+		}
+	}
+	return resources
+}
+
+// schemaField describes a field of a message in the schema unit tests check
+// responses against.
+type schemaField struct {
+	Name     string `json:"name"`
+	JSONName string `json:"jsonName"`
+	// Type is one of the JSON types checked by unitTestCode, or "message",
+	// "enum" or "map".
+	Type     string       `json:"type"`
+	Repeated bool         `json:"repeated,omitempty"`
+	Required bool         `json:"required,omitempty"`
+	TypeName string       `json:"typeName,omitempty"` // Message or enum name, for those types.
+	Value    *schemaField `json:"value,omitempty"`    // Value of a map field.
+}
+
+// responseSchema describes a message and every message and enum it refers to,
+// keyed by their fully-qualified names.
+type responseSchema struct {
+	Message  string                   `json:"message"`
+	Messages map[string][]schemaField `json:"messages"`
+	Enums    map[string][]string      `json:"enums"`
+}
+
+func (e *insomniaenv) responseSchema(messageDefinition *typemap.MessageDefinition) responseSchema {
+	schema := responseSchema{
+		Message:  messageDefinition.ProtoName(),
+		Messages: map[string][]schemaField{},
+		Enums:    map[string][]string{},
+	}
+	e.addSchemaMessage(&schema, messageDefinition)
+	return schema
+}
+
+func (e *insomniaenv) addSchemaMessage(schema *responseSchema, messageDefinition *typemap.MessageDefinition) {
+	name := messageDefinition.ProtoName()
+	if _, ok := schema.Messages[name]; ok {
+		return
+	}
+	// Register the message before visiting its fields, so recursive messages
+	// terminate.
+	schema.Messages[name] = nil
+
+	fields := []schemaField{}
+	for _, field := range messageDefinition.Descriptor.Field {
+		f := e.schemaMessageField(schema, field)
+		f.Name = field.GetName()
+		f.JSONName = field.GetJsonName()
+		fields = append(fields, f)
+	}
+	// Extensions are keyed by their fully-qualified names in brackets.
+	for _, ext := range e.mocks.Extensions(name) {
+		f := e.schemaMessageField(schema, ext.Field)
+		f.Name = "[" + ext.Name + "]"
+		f.JSONName = f.Name
+		fields = append(fields, f)
+	}
+	schema.Messages[name] = fields
+}
+
+// schemaMessageField returns the type and label of field, ignoring its name.
+func (e *insomniaenv) schemaMessageField(schema *responseSchema, field *descriptor.FieldDescriptorProto) schemaField {
+	f := e.schemaField(schema, field)
+	f.Repeated = field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+	f.Required = e.presence.Of(field) == presence.Required
+	if msg := e.registry.MessageDefinition(field.GetTypeName()); msg != nil && msg.Descriptor.GetOptions().GetMapEntry() {
+		value := e.schemaField(schema, msg.Descriptor.Field[1])
+		f = schemaField{Type: "map", Value: &value}
+	}
+	return f
+}
+
+// schemaField returns the type of field, ignoring its name and label.
+func (e *insomniaenv) schemaField(schema *responseSchema, field *descriptor.FieldDescriptorProto) schemaField {
+	switch field.GetTypeName() {
+	case ".google.protobuf.Timestamp", ".google.protobuf.Duration", ".google.protobuf.FieldMask":
+		return schemaField{Type: "string"}
+	case ".google.protobuf.Struct", ".google.protobuf.Value", ".google.protobuf.ListValue", ".google.protobuf.Any":
+		return schemaField{Type: "any"}
+	case ".google.protobuf.StringValue", ".google.protobuf.BytesValue":
+		return schemaField{Type: "string"}
+	case ".google.protobuf.BoolValue":
+		return schemaField{Type: "bool"}
+	case ".google.protobuf.Int32Value", ".google.protobuf.UInt32Value", ".google.protobuf.Int64Value", ".google.protobuf.UInt64Value":
+		return schemaField{Type: "integer"}
+	case ".google.protobuf.FloatValue", ".google.protobuf.DoubleValue":
+		return schemaField{Type: "number"}
+	}
+
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return schemaField{Type: "number"}
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return schemaField{Type: "bool"}
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return schemaField{Type: "string"}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		values := []string{}
+		if enum := e.mocks.Enum(field.GetTypeName()); enum != nil {
+			for _, value := range enum.GetValue() {
+				values = append(values, value.GetName())
+			}
+		}
+		schema.Enums[field.GetTypeName()] = values
+		return schemaField{Type: "enum", TypeName: field.GetTypeName()}
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		msg := e.registry.MessageDefinition(field.GetTypeName())
+		if msg == nil {
+			return schemaField{Type: "any"}
+		}
+		e.addSchemaMessage(schema, msg)
+		return schemaField{Type: "message", TypeName: field.GetTypeName()}
+	}
+	// All remaining types are integers. 64-bit integers are encoded as JSON
+	// strings, which "integer" allows.
+	return schemaField{Type: "integer"}
+}
+
+// unitTestCode is the JavaScript run by each unit test. It's formatted with
+// the JSON encoded responseSchema of the method's output message.
+const unitTestCode = `const schema = %s;
+
+function check(value, field, path, errors) {
+	if (value === null) {
+		return;
+	}
+	switch (field.type) {
+	case 'any':
+		return;
+	case 'string':
+		if (typeof value !== 'string') errors.push(path + ' should be a string');
+		return;
+	case 'bool':
+		if (typeof value !== 'boolean') errors.push(path + ' should be a boolean');
+		return;
+	case 'number':
+		if (typeof value !== 'number' && !['NaN', 'Infinity', '-Infinity'].includes(value) && !(typeof value === 'string' && !isNaN(Number(value)))) {
+			errors.push(path + ' should be a number');
+		}
+		return;
+	case 'integer':
+		if (!Number.isInteger(typeof value === 'string' ? Number(value) : value)) errors.push(path + ' should be an integer');
+		return;
+	case 'enum':
+		if (!schema.enums[field.typeName].includes(value) && !Number.isInteger(value)) {
+			errors.push(path + ' should be one of ' + schema.enums[field.typeName].join(', '));
+		}
+		return;
+	case 'map':
+		if (typeof value !== 'object' || Array.isArray(value)) {
+			errors.push(path + ' should be an object');
+			return;
+		}
+		for (const key of Object.keys(value)) {
+			check(value[key], field.value, path + '[' + JSON.stringify(key) + ']', errors);
+		}
+		return;
+	case 'message':
+		checkMessage(value, field.typeName, path, errors);
+		return;
+	}
+}
+
+function checkMessage(value, typeName, path, errors) {
+	if (typeof value !== 'object' || Array.isArray(value)) {
+		errors.push(path + ' should be an object');
+		return;
+	}
+	const fields = schema.messages[typeName];
+	for (const key of Object.keys(value)) {
+		const field = fields.find(f => f.jsonName === key || f.name === key);
+		if (!field) {
+			errors.push(path + '.' + key + ' is not a field of ' + typeName.substring(1));
+			continue;
+		}
+		if (field.repeated && field.type !== 'map') {
+			if (!Array.isArray(value[key])) {
+				errors.push(path + '.' + key + ' should be a list');
+				continue;
+			}
+			value[key].forEach((elem, i) => check(elem, field, path + '.' + key + '[' + i + ']', errors));
+			continue;
+		}
+		check(value[key], field, path + '.' + key, errors);
+	}
+	for (const field of fields) {
+		if (field.required && !(field.jsonName in value) && !(field.name in value)) {
+			errors.push(path + '.' + field.jsonName + ' is required');
+		}
+	}
+}
+
+const response = await insomnia.send();
+expect(response.status).to.equal(200);
+
+const errors = [];
+checkMessage(JSON.parse(response.data), schema.message, 'response', errors);
+expect(errors).to.be.empty;
+`
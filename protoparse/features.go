@@ -0,0 +1,88 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// featureSetEnums are the enums of google.protobuf.FeatureSet, in the order
+// of the fields holding them, which are numbered from 1.
+var featureSetEnums = []struct {
+	field  string
+	enum   string
+	values []string
+}{
+	{"field_presence", "FieldPresence", []string{"FIELD_PRESENCE_UNKNOWN", "EXPLICIT", "IMPLICIT", "LEGACY_REQUIRED"}},
+	{"enum_type", "EnumType", []string{"ENUM_TYPE_UNKNOWN", "OPEN", "CLOSED"}},
+	{"repeated_field_encoding", "RepeatedFieldEncoding", []string{"REPEATED_FIELD_ENCODING_UNKNOWN", "PACKED", "EXPANDED"}},
+	{"utf8_validation", "Utf8Validation", []string{"UTF8_VALIDATION_UNKNOWN", "", "VERIFY", "NONE"}},
+	{"message_encoding", "MessageEncoding", []string{"MESSAGE_ENCODING_UNKNOWN", "LENGTH_PREFIXED", "DELIMITED"}},
+	{"json_format", "JsonFormat", []string{"JSON_FORMAT_UNKNOWN", "ALLOW", "LEGACY_BEST_EFFORT"}},
+}
+
+// featuresFields are the numbers of the features fields of the options
+// messages.
+var featuresFields = map[string]int32{
+	"FileOptions":      50,
+	"MessageOptions":   12,
+	"FieldOptions":     21,
+	"OneofOptions":     1,
+	"EnumOptions":      7,
+	"EnumValueOptions": 2,
+	"ServiceOptions":   34,
+	"MethodOptions":    35,
+}
+
+// addFeatures adds google.protobuf.FeatureSet, and the features fields of the
+// options messages, to fd, the descriptor of descriptor.proto registered by
+// the vendored descriptor package, which predates editions. Files using
+// editions can then set features like any other option.
+func addFeatures(fd *descriptor.FileDescriptorProto) {
+	featureSet := &descriptor.DescriptorProto{Name: proto.String("FeatureSet")}
+	for i, f := range featureSetEnums {
+		enum := &descriptor.EnumDescriptorProto{Name: proto.String(f.enum)}
+		for number, value := range f.values {
+			if value != "" {
+				enum.Value = append(enum.Value, &descriptor.EnumValueDescriptorProto{Name: proto.String(value), Number: proto.Int32(int32(number))})
+			}
+		}
+		featureSet.EnumType = append(featureSet.EnumType, enum)
+		featureSet.Field = append(featureSet.Field, &descriptor.FieldDescriptorProto{
+			Name:     proto.String(f.field),
+			JsonName: proto.String(jsonName(f.field)),
+			Number:   proto.Int32(int32(i + 1)),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptor.FieldDescriptorProto_TYPE_ENUM.Enum(),
+			TypeName: proto.String(".google.protobuf.FeatureSet." + f.enum),
+		})
+	}
+	fd.MessageType = append(fd.MessageType, featureSet)
+
+	for _, msg := range fd.MessageType {
+		number, ok := featuresFields[msg.GetName()]
+		if !ok {
+			continue
+		}
+		msg.Field = append(msg.Field, &descriptor.FieldDescriptorProto{
+			Name:     proto.String("features"),
+			JsonName: proto.String("features"),
+			Number:   proto.Int32(number),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(".google.protobuf.FeatureSet"),
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+// insomniaOptionsName is the name of the file declaring the options of
+// protoc-gen-insomniaenv.
+const insomniaOptionsName = "insomnia/options.proto"
+
+// insomniaOptions is the source of proto/insomnia/options.proto, built in like
+// the well-known types so that files using the plugin's options can be parsed
+// without the plugin's proto directory on the import path. It must be kept
+// the same as that file.
+const insomniaOptions = `// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Options which configure protoc-gen-insomniaenv from within proto files. Add
+// this directory to protoc's import path and import "insomnia/options.proto" to
+// use them.
+syntax = "proto3";
+
+package insomnia;
+
+import "google/protobuf/descriptor.proto";
+
+// ServiceOptions configure the requests generated for a service.
+message ServiceOptions {
+  // Path the service is mounted under, overriding the path_prefix parameter.
+  // Use "/" to mount the service at the root.
+  string path_prefix = 1;
+  // Authentication of the service's requests, overriding the auth parameter.
+  Auth auth = 2;
+  // Headers added to the service's requests, after those of the headers
+  // parameter.
+  repeated Header headers = 3;
+  // Environment variable holding the base URL of the service's requests,
+  // instead of base_url. It's added to the generated environments, set to the
+  // same URL as base_url, so it only needs changing for services hosted
+  // elsewhere.
+  string base_url_variable = 4;
+  // Name of the folder holding the service's requests, instead of the name
+  // of the service.
+  string folder = 5;
+}
+
+// MethodOptions configure the request generated for a method.
+message MethodOptions {
+  // Authentication of the method's request, overriding the service's.
+  Auth auth = 1;
+  // Headers added to the method's request, after those of its service.
+  repeated Header headers = 2;
+  // Leave the method out of the generated workspace.
+  bool skip = 3;
+  // Additional requests to the method, each sending its own body.
+  repeated Example examples = 4;
+  // Name of a folder within the service's folder holding the method's
+  // requests. Methods with the same folder share it.
+  string folder = 5;
+  // How the method's request body and example response are mocked,
+  // overriding the mock_strategy parameter. One of "full", "minimal", "zero"
+  // or "random".
+  string mock_strategy = 6;
+}
+
+// FieldOptions configure how a field is mocked in request bodies and example
+// responses.
+message FieldOptions {
+  // JSON value used for the field instead of a random one, such as
+  // "\"blue\"" or "[1, 2]". The value of a repeated field is the whole list.
+  string example = 1;
+  // Leave the field out of mock messages.
+  bool skip = 2;
+  // Kind of value generated for a string field. One of "uuid", "email",
+  // "url", "hostname", "ipv4" or "phone".
+  string hint = 3;
+}
+
+// Example is a request to a method with a hand-written body.
+message Example {
+  // Name of the request, which is shown after the method's name.
+  string name = 1;
+  // Body of the request, as JSON.
+  string body = 2;
+}
+
+// Auth configures how requests authenticate. Credentials are read from
+// environment variables of the generated workspace, so they never need to be
+// written into proto files.
+message Auth {
+  // One of "none", "bearer", "apikey", "basic" or "oauth2". OAuth 2 requests
+  // use the client credentials grant.
+  string type = 1;
+  // Header carrying the key of "apikey" authentication, overriding the
+  // api_key_header parameter.
+  string api_key_header = 2;
+}
+
+// Header is a header added to requests. Its value can use Insomnia template
+// tags, such as {% uuid 'v4' %}, which are rendered every time the request is
+// sent. A header replaces any earlier header of the same name.
+message Header {
+  string name = 1;
+  string value = 2;
+}
+
+extend google.protobuf.ServiceOptions {
+  ServiceOptions service = 51230;
+}
+
+extend google.protobuf.MethodOptions {
+  MethodOptions method = 51231;
+}
+
+extend google.protobuf.FieldOptions {
+  FieldOptions field = 51232;
+}
+`
@@ -0,0 +1,362 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenSymbol
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of file"
+	case tokenIdent:
+		return "identifier"
+	case tokenInt:
+		return "integer"
+	case tokenFloat:
+		return "number"
+	case tokenString:
+		return "string"
+	}
+	return "symbol"
+}
+
+// position is a zero-based line and column in a file.
+type position struct {
+	line, col int
+}
+
+// comment is a block of comments: a block comment, or consecutive line
+// comments on consecutive lines.
+type comment struct {
+	start, end position
+	text       string
+	// trailing is set for comments starting on the line of the preceding
+	// token.
+	trailing bool
+	// line is set for line comments.
+	line bool
+}
+
+// token is a lexical token of a .proto file. For strings, text is the
+// unquoted value.
+type token struct {
+	kind tokenKind
+	text string
+	pos  position
+	end  position
+	// comments lists the comments between the previous token and this one.
+	comments []comment
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexer splits the contents of a .proto file into tokens.
+type lexer struct {
+	filename string
+	src      string
+	offset   int
+	pos      position
+	// prevLine is the line the previous token ended on.
+	prevLine int
+}
+
+func newLexer(filename, src string) *lexer {
+	return &lexer{filename: filename, src: src, prevLine: -1}
+}
+
+func (l *lexer) errorf(pos position, format string, args ...interface{}) *Error {
+	return &Error{
+		Filename: l.filename,
+		Line:     pos.line + 1,
+		Column:   pos.col + 1,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *lexer) peekByte(n int) byte {
+	if l.offset+n < len(l.src) {
+		return l.src[l.offset+n]
+	}
+	return 0
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.offset]
+	l.offset++
+	if c == '\n' {
+		l.pos.line++
+		l.pos.col = 0
+	} else {
+		l.pos.col++
+	}
+	return c
+}
+
+// next returns the next token, along with the comments preceding it.
+func (l *lexer) next() (token, error) {
+	comments, err := l.skipSpaceAndComments()
+	if err != nil {
+		return token{}, err
+	}
+	tok := token{pos: l.pos, comments: comments}
+	if l.offset >= len(l.src) {
+		tok.kind = tokenEOF
+		tok.end = l.pos
+		return tok, nil
+	}
+
+	c := l.peekByte(0)
+	switch {
+	case isLetter(c):
+		start := l.offset
+		for l.offset < len(l.src) && (isLetter(l.peekByte(0)) || isDigit(l.peekByte(0))) {
+			l.advance()
+		}
+		tok.kind = tokenIdent
+		tok.text = l.src[start:l.offset]
+	case isDigit(c) || (c == '.' && isDigit(l.peekByte(1))):
+		tok.kind, tok.text = l.number()
+	case c == '"' || c == '\'':
+		text, err := l.str()
+		if err != nil {
+			return token{}, err
+		}
+		tok.kind = tokenString
+		tok.text = text
+	default:
+		l.advance()
+		tok.kind = tokenSymbol
+		tok.text = string(c)
+	}
+	tok.end = l.pos
+	l.prevLine = l.pos.line
+	return tok, nil
+}
+
+func (l *lexer) number() (tokenKind, string) {
+	start := l.offset
+	kind := tokenInt
+	if l.peekByte(0) == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X') {
+		l.advance()
+		l.advance()
+		for isHexDigit(l.peekByte(0)) {
+			l.advance()
+		}
+		return kind, l.src[start:l.offset]
+	}
+	for l.offset < len(l.src) {
+		c := l.peekByte(0)
+		switch {
+		case isDigit(c):
+		case c == '.':
+			kind = tokenFloat
+		case c == 'e' || c == 'E':
+			kind = tokenFloat
+			if n := l.peekByte(1); n == '+' || n == '-' {
+				l.advance()
+			}
+		default:
+			return kind, l.src[start:l.offset]
+		}
+		l.advance()
+	}
+	return kind, l.src[start:l.offset]
+}
+
+// str reads a quoted string, interpreting its escape sequences.
+func (l *lexer) str() (string, error) {
+	start := l.pos
+	quote := l.advance()
+	var b strings.Builder
+	for {
+		if l.offset >= len(l.src) || l.peekByte(0) == '\n' {
+			return "", l.errorf(start, "unterminated string")
+		}
+		c := l.advance()
+		if c == quote {
+			return b.String(), nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if l.offset >= len(l.src) {
+			return "", l.errorf(start, "unterminated string")
+		}
+		escPos := l.pos
+		e := l.advance()
+		switch e {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			b.WriteByte(e)
+		case 'x', 'X':
+			n := 0
+			v := 0
+			for n < 2 && isHexDigit(l.peekByte(0)) {
+				v = v*16 + hexValue(l.advance())
+				n++
+			}
+			if n == 0 {
+				return "", l.errorf(escPos, "expected hex digits after \\x")
+			}
+			b.WriteByte(byte(v))
+		case 'u', 'U':
+			digits := 4
+			if e == 'U' {
+				digits = 8
+			}
+			v := 0
+			for i := 0; i < digits; i++ {
+				if !isHexDigit(l.peekByte(0)) {
+					return "", l.errorf(escPos, "expected %d hex digits after \\%c", digits, e)
+				}
+				v = v*16 + hexValue(l.advance())
+			}
+			b.WriteRune(rune(v))
+		default:
+			if e < '0' || e > '7' {
+				return "", l.errorf(escPos, "invalid escape sequence \\%c", e)
+			}
+			v := int(e - '0')
+			for n := 1; n < 3 && l.peekByte(0) >= '0' && l.peekByte(0) <= '7'; n++ {
+				v = v*8 + int(l.advance()-'0')
+			}
+			b.WriteByte(byte(v))
+		}
+	}
+}
+
+// skipSpaceAndComments skips to the start of the next token, returning the
+// comments it skipped.
+func (l *lexer) skipSpaceAndComments() ([]comment, error) {
+	var comments []comment
+	for l.offset < len(l.src) {
+		c := l.peekByte(0)
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			l.advance()
+		case c == '/' && l.peekByte(1) == '/':
+			start := l.pos
+			l.advance()
+			l.advance()
+			begin := l.offset
+			for l.offset < len(l.src) && l.peekByte(0) != '\n' {
+				l.advance()
+			}
+			text := strings.TrimSuffix(l.src[begin:l.offset], "\r") + "\n"
+			trailing := start.line == l.prevLine
+			// Line comments on consecutive lines form a single block,
+			// unless the first follows a token on its line.
+			if n := len(comments); n > 0 && comments[n-1].line && !comments[n-1].trailing && !trailing && comments[n-1].end.line == start.line-1 {
+				comments[n-1].text += text
+				comments[n-1].end = l.pos
+				continue
+			}
+			comments = append(comments, comment{start: start, end: l.pos, text: text, trailing: trailing, line: true})
+		case c == '/' && l.peekByte(1) == '*':
+			start := l.pos
+			l.advance()
+			l.advance()
+			begin := l.offset
+			for {
+				if l.offset >= len(l.src) {
+					return nil, l.errorf(start, "unterminated comment")
+				}
+				if l.peekByte(0) == '*' && l.peekByte(1) == '/' {
+					break
+				}
+				l.advance()
+			}
+			text := blockCommentText(l.src[begin:l.offset])
+			l.advance()
+			l.advance()
+			comments = append(comments, comment{start: start, end: l.pos, text: text, trailing: start.line == l.prevLine})
+		default:
+			return comments, nil
+		}
+	}
+	return comments, nil
+}
+
+// blockCommentText strips the leading whitespace and asterisks from the
+// lines of a block comment, as protoc does.
+func blockCommentText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t")
+		if strings.HasPrefix(line, "*") {
+			line = line[1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	}
+	return int(c - '0')
+}
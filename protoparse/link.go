@@ -0,0 +1,159 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// symbol is a named definition: a package, message, enum, extension or
+// service.
+type symbol struct {
+	file string
+	pkg  bool
+	msg  *descriptor.DescriptorProto
+	enum *descriptor.EnumDescriptorProto
+	ext  *descriptor.FieldDescriptorProto
+}
+
+// linker resolves the names used by parsed files, across every file.
+type linker struct {
+	symbols map[string]*symbol
+	errs    ErrorList
+}
+
+func newLinker() *linker {
+	return &linker{symbols: map[string]*symbol{}}
+}
+
+// addFile defines the symbols of fd, reporting those already defined.
+func (l *linker) addFile(fd *descriptor.FileDescriptorProto) {
+	spans := map[string][]int32{}
+	if fd.SourceCodeInfo != nil {
+		for _, loc := range fd.SourceCodeInfo.Location {
+			spans[pathKey(loc.Path)] = loc.Span
+		}
+	}
+	define := func(name string, sym *symbol, path []int32) {
+		sym.file = fd.GetName()
+		if prev, ok := l.symbols[name]; ok && !(prev.pkg && sym.pkg) {
+			pos := position{}
+			if span := spans[pathKey(path)]; len(span) >= 2 {
+				pos = position{line: int(span[0]), col: int(span[1])}
+			}
+			where := ""
+			if prev.file != sym.file {
+				where = " in " + prev.file
+			}
+			l.errs = append(l.errs, newLexer(fd.GetName(), "").errorf(pos, "%q is already defined%s", strings.TrimPrefix(name, "."), where))
+			return
+		}
+		l.symbols[name] = sym
+	}
+
+	scope := ""
+	if fd.GetPackage() != "" {
+		for _, part := range strings.Split(fd.GetPackage(), ".") {
+			scope += "." + part
+			define(scope, &symbol{pkg: true}, nil)
+		}
+	}
+	var addMessage func(msg *descriptor.DescriptorProto, scope string, path []int32)
+	addEnum := func(enum *descriptor.EnumDescriptorProto, scope string, path []int32) {
+		define(scope+"."+enum.GetName(), &symbol{enum: enum}, path)
+	}
+	addExtension := func(ext *descriptor.FieldDescriptorProto, scope string, path []int32) {
+		define(scope+"."+ext.GetName(), &symbol{ext: ext}, path)
+	}
+	addMessage = func(msg *descriptor.DescriptorProto, scope string, path []int32) {
+		name := scope + "." + msg.GetName()
+		define(name, &symbol{msg: msg}, path)
+		for i, nested := range msg.NestedType {
+			addMessage(nested, name, appendPath(path, messageNestedTag, i))
+		}
+		for i, enum := range msg.EnumType {
+			addEnum(enum, name, appendPath(path, messageEnumTag, i))
+		}
+		for i, ext := range msg.Extension {
+			addExtension(ext, name, appendPath(path, messageExtensionTag, i))
+		}
+	}
+	for i, msg := range fd.MessageType {
+		addMessage(msg, scope, []int32{fileMessageTag, int32(i)})
+	}
+	for i, enum := range fd.EnumType {
+		addEnum(enum, scope, []int32{fileEnumTag, int32(i)})
+	}
+	for i, ext := range fd.Extension {
+		addExtension(ext, scope, []int32{fileExtensionTag, int32(i)})
+	}
+	for i, service := range fd.Service {
+		define(scope+"."+service.GetName(), &symbol{}, []int32{fileServiceTag, int32(i)})
+	}
+}
+
+func appendPath(path []int32, tag int32, index int) []int32 {
+	return append(append([]int32(nil), path...), tag, int32(index))
+}
+
+func pathKey(path []int32) string {
+	return fmt.Sprint(path)
+}
+
+// resolve looks name up from scope, like protoc: in scope first, then in
+// each enclosing scope. Names starting with a dot are fully-qualified.
+func (l *linker) resolve(scope, name string) (string, *symbol) {
+	if strings.HasPrefix(name, ".") {
+		return name, l.symbols[name]
+	}
+	for {
+		fullName := scope + "." + name
+		if sym, ok := l.symbols[fullName]; ok && !sym.pkg {
+			return fullName, sym
+		}
+		if scope == "" {
+			return "", nil
+		}
+		scope = scope[:strings.LastIndex(scope, ".")]
+	}
+}
+
+// link resolves the type references and options of pf, recording any
+// problems it finds.
+func (l *linker) link(pf *parsedFile) {
+	lex := newLexer(pf.fd.GetName(), "")
+	for _, ref := range pf.refs {
+		fullName, sym := l.resolve(ref.scope, ref.name)
+		if sym == nil {
+			l.errs = append(l.errs, lex.errorf(ref.pos, "%q is not defined", ref.name))
+			continue
+		}
+		if msg := ref.set(fullName, sym); msg != "" {
+			l.errs = append(l.errs, lex.errorf(ref.pos, "%q %s", ref.name, msg))
+		}
+	}
+	if len(l.errs) > 0 {
+		// Options can't be encoded until the types they use are known.
+		return
+	}
+	for _, o := range pf.options {
+		if err := l.applyOption(o); err != nil {
+			err.Filename = pf.fd.GetName()
+			l.errs = append(l.errs, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// applyOption encodes an option in the protobuf wire format, the way protoc
+// stores options it doesn't know, and merges it into its options message.
+// Extensions are then decoded by proto.GetExtension, like those of
+// descriptors parsed by protoc.
+func (l *linker) applyOption(o *pendingOption) *Error {
+	lex := newLexer("", "")
+	msgName := o.target
+	fields := make([]*descriptor.FieldDescriptorProto, len(o.name))
+	for i, part := range o.name {
+		msg := l.symbols[msgName].msg
+		field := l.findField(msg, msgName, o.scope, part.name, part.ext)
+		if field == nil {
+			return lex.errorf(part.pos, "option %q is unknown", optionNameString(o.name[:i+1]))
+		}
+		if i < len(o.name)-1 {
+			if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE && field.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP {
+				return lex.errorf(part.pos, "option %q is not a message", optionNameString(o.name[:i+1]))
+			}
+			if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				return lex.errorf(part.pos, "option %q is repeated, so its fields can't be set individually", optionNameString(o.name[:i+1]))
+			}
+			msgName = field.GetTypeName()
+		}
+		fields[i] = field
+	}
+
+	b, err := l.encodeField(fields[len(fields)-1], o.value, o.scope)
+	if err != nil {
+		return err
+	}
+	for i := len(fields) - 2; i >= 0; i-- {
+		b = encodeMessageField(fields[i], b)
+	}
+	if err := proto.UnmarshalMerge(b, o.options()); err != nil {
+		return lex.errorf(o.pos, "invalid option %q: %v", optionNameString(o.name), err)
+	}
+	return nil
+}
+
+func optionNameString(name []optionNamePart) string {
+	parts := make([]string, len(name))
+	for i, part := range name {
+		parts[i] = part.name
+		if part.ext {
+			parts[i] = "(" + part.name + ")"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// findField returns the field of msg, named msgName, called name. Extensions
+// are resolved from scope. The fields of groups can also be referred to by
+// the name of the group's message, as in the text format.
+func (l *linker) findField(msg *descriptor.DescriptorProto, msgName, scope, name string, ext bool) *descriptor.FieldDescriptorProto {
+	if ext {
+		_, sym := l.resolve(scope, name)
+		if sym == nil || sym.ext == nil || sym.ext.GetExtendee() != msgName {
+			return nil
+		}
+		return sym.ext
+	}
+	for _, field := range msg.Field {
+		if field.GetName() == name {
+			return field
+		}
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP && strings.HasSuffix(field.GetTypeName(), "."+name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// encodeMessageField encodes the encoded message b as the value of field.
+func encodeMessageField(field *descriptor.FieldDescriptorProto, b []byte) []byte {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		start := proto.EncodeVarint(uint64(field.GetNumber())<<3 | proto.WireStartGroup)
+		end := proto.EncodeVarint(uint64(field.GetNumber())<<3 | proto.WireEndGroup)
+		return append(append(start, b...), end...)
+	}
+	buf := proto.NewBuffer(nil)
+	buf.EncodeVarint(uint64(field.GetNumber())<<3 | proto.WireBytes)
+	buf.EncodeRawBytes(b)
+	return buf.Bytes()
+}
+
+// encodeField encodes v as the value of field, including its key.
+func (l *linker) encodeField(field *descriptor.FieldDescriptorProto, v *optionValue, scope string) ([]byte, *Error) {
+	lex := newLexer("", "")
+	if v.kind == valueList {
+		if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return nil, lex.errorf(v.pos, "%s is not repeated", field.GetName())
+		}
+		var b []byte
+		for _, item := range v.list {
+			itemBytes, err := l.encodeField(field, item, scope)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, itemBytes...)
+		}
+		return b, nil
+	}
+
+	buf := proto.NewBuffer(nil)
+	key := func(wireType uint64) {
+		buf.EncodeVarint(uint64(field.GetNumber())<<3 | wireType)
+	}
+	typeError := func(expected string) *Error {
+		return lex.errorf(v.pos, "%s must be %s", field.GetName(), expected)
+	}
+
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if v.kind != valueAggregate {
+			return nil, typeError("a message, such as { name: value }")
+		}
+		b, err := l.encodeAggregate(field.GetTypeName(), v.fields, scope)
+		if err != nil {
+			return nil, err
+		}
+		return encodeMessageField(field, b), nil
+
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		enum := l.symbols[field.GetTypeName()].enum
+		if v.kind != valueIdent || v.neg {
+			return nil, typeError("a value of enum " + strings.TrimPrefix(field.GetTypeName(), "."))
+		}
+		for _, value := range enum.Value {
+			if value.GetName() == v.text {
+				key(proto.WireVarint)
+				buf.EncodeVarint(uint64(int64(value.GetNumber())))
+				return buf.Bytes(), nil
+			}
+		}
+		return nil, lex.errorf(v.pos, "enum %s has no value named %s", strings.TrimPrefix(field.GetTypeName(), "."), v.text)
+
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if v.kind != valueIdent || v.neg || (v.text != "true" && v.text != "false") {
+			return nil, typeError("true or false")
+		}
+		key(proto.WireVarint)
+		if v.text == "true" {
+			buf.EncodeVarint(1)
+		} else {
+			buf.EncodeVarint(0)
+		}
+		return buf.Bytes(), nil
+
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		if v.kind != valueString {
+			return nil, typeError("a string")
+		}
+		key(proto.WireBytes)
+		buf.EncodeStringBytes(v.text)
+		return buf.Bytes(), nil
+
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		f, ok := floatValue(v)
+		if !ok {
+			return nil, typeError("a number")
+		}
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_FLOAT {
+			key(proto.WireFixed32)
+			buf.EncodeFixed32(uint64(math.Float32bits(float32(f))))
+		} else {
+			key(proto.WireFixed64)
+			buf.EncodeFixed64(math.Float64bits(f))
+		}
+		return buf.Bytes(), nil
+	}
+
+	if v.kind != valueInt {
+		return nil, typeError("an integer")
+	}
+	n, err := strconv.ParseUint(v.text, 0, 64)
+	if err != nil {
+		return nil, lex.errorf(v.pos, "%s is out of range", v.text)
+	}
+	var min, max int64
+	var umax uint64
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		min, max = math.MinInt32, math.MaxInt32
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		min, max = math.MinInt64, math.MaxInt64
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		umax = math.MaxUint32
+	default:
+		umax = math.MaxUint64
+	}
+	var x int64
+	if umax != 0 {
+		if v.neg || n > umax {
+			return nil, lex.errorf(v.pos, "value out of range for %s", field.GetName())
+		}
+		x = int64(n)
+	} else {
+		if (!v.neg && n > uint64(max)) || (v.neg && n > uint64(-(min+1))+1) {
+			return nil, lex.errorf(v.pos, "value out of range for %s", field.GetName())
+		}
+		x = int64(n)
+		if v.neg {
+			x = -x
+		}
+	}
+
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		key(proto.WireVarint)
+		buf.EncodeZigzag32(uint64(x))
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		key(proto.WireVarint)
+		buf.EncodeZigzag64(uint64(x))
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		key(proto.WireFixed32)
+		buf.EncodeFixed32(uint64(x))
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		key(proto.WireFixed64)
+		buf.EncodeFixed64(uint64(x))
+	default:
+		key(proto.WireVarint)
+		buf.EncodeVarint(uint64(x))
+	}
+	return buf.Bytes(), nil
+}
+
+// floatValue returns the value of a number, or of inf or nan.
+func floatValue(v *optionValue) (float64, bool) {
+	var f float64
+	switch v.kind {
+	case valueInt, valueFloat:
+		var err error
+		if f, err = strconv.ParseFloat(v.text, 64); err != nil {
+			n, err := strconv.ParseUint(v.text, 0, 64)
+			if err != nil {
+				return 0, false
+			}
+			f = float64(n)
+		}
+	case valueIdent:
+		switch strings.ToLower(v.text) {
+		case "inf", "infinity":
+			f = math.Inf(1)
+		case "nan":
+			f = math.NaN()
+		default:
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if v.neg {
+		f = -f
+	}
+	return f, true
+}
+
+// encodeAggregate encodes the fields of an aggregate value of the message
+// named msgName.
+func (l *linker) encodeAggregate(msgName string, fields []*aggregateField, scope string) ([]byte, *Error) {
+	sym := l.symbols[msgName]
+	var b []byte
+	for _, f := range fields {
+		field := l.findField(sym.msg, msgName, scope, f.name, f.ext)
+		if field == nil {
+			name := f.name
+			if f.ext {
+				name = "[" + name + "]"
+			}
+			return nil, newLexer("", "").errorf(f.pos, "%s has no field named %s", strings.TrimPrefix(msgName, "."), name)
+		}
+		fieldBytes, err := l.encodeField(field, f.value, scope)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, fieldBytes...)
+	}
+	return b, nil
+}
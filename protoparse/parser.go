@@ -0,0 +1,1289 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Paths of the SourceCodeInfo locations of definitions, which are made of the
+// numbers of the fields holding them in their parent descriptor.
+const (
+	fileMessageTag   = 4
+	fileEnumTag      = 5
+	fileServiceTag   = 6
+	fileExtensionTag = 7
+
+	messageFieldTag     = 2
+	messageNestedTag    = 3
+	messageEnumTag      = 4
+	messageExtensionTag = 6
+	messageOneofTag     = 8
+
+	enumValueTag     = 2
+	serviceMethodTag = 2
+)
+
+// Numbers of the descriptor fields which the vendored descriptors predate,
+// so they're encoded as unknown fields.
+const (
+	fileEditionTag         = 14
+	fieldProto3OptionalTag = 17
+)
+
+// edition2023 is the value of the Edition enum for edition "2023".
+const edition2023 = 1000
+
+const maxFieldNumber = 536870911
+
+var scalarTypes = map[string]descriptor.FieldDescriptorProto_Type{
+	"double":   descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptor.FieldDescriptorProto_TYPE_FLOAT,
+	"int64":    descriptor.FieldDescriptorProto_TYPE_INT64,
+	"uint64":   descriptor.FieldDescriptorProto_TYPE_UINT64,
+	"int32":    descriptor.FieldDescriptorProto_TYPE_INT32,
+	"fixed64":  descriptor.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32":  descriptor.FieldDescriptorProto_TYPE_FIXED32,
+	"bool":     descriptor.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptor.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptor.FieldDescriptorProto_TYPE_BYTES,
+	"uint32":   descriptor.FieldDescriptorProto_TYPE_UINT32,
+	"sfixed32": descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+	"sint32":   descriptor.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptor.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// parsedFile is a file whose syntax has been parsed, but whose references to
+// other definitions and options are yet to be resolved.
+type parsedFile struct {
+	fd      *descriptor.FileDescriptorProto
+	refs    []*typeRef
+	options []*pendingOption
+	// importPos holds the position of each import statement, by file name.
+	importPos map[string]position
+}
+
+// typeRef is a reference to a message or enum by name, which is resolved
+// once every file has been parsed.
+type typeRef struct {
+	pos   position
+	scope string // Fully-qualified name of the scope the name is used in.
+	name  string
+	// set records the fully-qualified name of the definition the name
+	// refers to, returning an error message if it can't be used there.
+	set func(fullName string, sym *symbol) string
+}
+
+// pendingOption is an option which is encoded once every file has been
+// parsed, since it can refer to extensions and enums defined anywhere.
+type pendingOption struct {
+	pos   position
+	scope string
+	// target is the fully-qualified name of the options message, such as
+	// .google.protobuf.FieldOptions.
+	target string
+	name   []optionNamePart
+	value  *optionValue
+	// options returns the options message the encoded option is merged
+	// into, creating it if needed.
+	options func() proto.Message
+}
+
+type optionNamePart struct {
+	name string
+	ext  bool // Set for extension names, which are written in parentheses.
+	pos  position
+}
+
+type valueKind int
+
+const (
+	valueIdent valueKind = iota
+	valueInt
+	valueFloat
+	valueString
+	valueAggregate
+	valueList
+)
+
+// optionValue is the value of an option, or of a field within an aggregate
+// option value.
+type optionValue struct {
+	pos    position
+	kind   valueKind
+	neg    bool
+	text   string // Identifier, numeric literal or string value.
+	fields []*aggregateField
+	list   []*optionValue
+}
+
+type aggregateField struct {
+	pos   position
+	name  string
+	ext   bool
+	value *optionValue
+}
+
+// parser parses a single file. Syntax errors are raised by panicking with an
+// *Error, which parse recovers.
+type parser struct {
+	lex    *lexer
+	tok    token
+	prev   token
+	file   *parsedFile
+	syntax string
+	pkg    string // Package of the file, with a leading dot, or "".
+	// proto3Optional holds the proto3 fields declared optional.
+	proto3Optional map[*descriptor.FieldDescriptorProto]bool
+}
+
+// parse parses the contents of the file named filename.
+func parse(filename, src string) (file *parsedFile, err error) {
+	p := &parser{
+		lex: newLexer(filename, src),
+		file: &parsedFile{
+			fd: &descriptor.FileDescriptorProto{
+				Name:           proto.String(filename),
+				SourceCodeInfo: &descriptor.SourceCodeInfo{},
+			},
+			importPos: map[string]position{},
+		},
+		syntax:         "proto2",
+		proto3Optional: map[*descriptor.FieldDescriptorProto]bool{},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			file, err = nil, e
+		}
+	}()
+	p.next()
+	p.parseFile()
+	return p.file, nil
+}
+
+func (p *parser) next() {
+	tok, err := p.lex.next()
+	if err != nil {
+		panic(err)
+	}
+	p.prev = p.tok
+	p.tok = tok
+}
+
+func (p *parser) errorf(pos position, format string, args ...interface{}) {
+	panic(p.lex.errorf(pos, format, args...))
+}
+
+// is reports whether the current token is the symbol or keyword s.
+func (p *parser) is(s string) bool {
+	return (p.tok.kind == tokenSymbol || p.tok.kind == tokenIdent) && p.tok.text == s
+}
+
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.accept(s) {
+		p.errorf(p.tok.pos, "expected %q, found %s", s, p.tok)
+	}
+}
+
+func (p *parser) ident() string {
+	if p.tok.kind != tokenIdent {
+		p.errorf(p.tok.pos, "expected identifier, found %s", p.tok)
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+// fullIdent parses a dotted name, which may start with a dot.
+func (p *parser) fullIdent() string {
+	var b strings.Builder
+	if p.accept(".") {
+		b.WriteString(".")
+	}
+	b.WriteString(p.ident())
+	for p.accept(".") {
+		b.WriteString(".")
+		b.WriteString(p.ident())
+	}
+	return b.String()
+}
+
+func (p *parser) stringLit() string {
+	if p.tok.kind != tokenString {
+		p.errorf(p.tok.pos, "expected string, found %s", p.tok)
+	}
+	var b strings.Builder
+	for p.tok.kind == tokenString {
+		b.WriteString(p.tok.text)
+		p.next()
+	}
+	return b.String()
+}
+
+// intLit parses an integer, optionally negative, within [min, max].
+func (p *parser) intLit(min, max int64) int64 {
+	pos := p.tok.pos
+	neg := p.accept("-")
+	if p.tok.kind != tokenInt {
+		p.errorf(p.tok.pos, "expected integer, found %s", p.tok)
+	}
+	v, err := strconv.ParseUint(p.tok.text, 0, 64)
+	p.next()
+	if err != nil || (!neg && v > uint64(max)) || (neg && v > uint64(-min)) {
+		p.errorf(pos, "integer out of range")
+	}
+	if neg {
+		return -int64(v)
+	}
+	return int64(v)
+}
+
+// startLocation records the location of the definition starting at the
+// current token, with the comments leading it.
+func (p *parser) startLocation(path []int32) *descriptor.SourceCodeInfo_Location {
+	loc := &descriptor.SourceCodeInfo_Location{
+		Path: append([]int32(nil), path...),
+		Span: []int32{int32(p.tok.pos.line), int32(p.tok.pos.col)},
+	}
+	comments := p.tok.comments
+	var detached []string
+	for i, c := range comments {
+		if c.trailing {
+			continue
+		}
+		last := i == len(comments)-1
+		if last && c.end.line >= p.tok.pos.line-1 {
+			loc.LeadingComments = proto.String(c.text)
+		} else {
+			detached = append(detached, c.text)
+		}
+	}
+	loc.LeadingDetachedComments = detached
+	p.file.fd.SourceCodeInfo.Location = append(p.file.fd.SourceCodeInfo.Location, loc)
+	return loc
+}
+
+// trailingComment records the comment following the previous token on its
+// line as the trailing comment of loc.
+func (p *parser) trailingComment(loc *descriptor.SourceCodeInfo_Location) {
+	for _, c := range p.tok.comments {
+		if c.trailing {
+			loc.TrailingComments = proto.String(c.text)
+		}
+	}
+}
+
+// endLocation completes the span of loc, which ends at the previous token.
+func (p *parser) endLocation(loc *descriptor.SourceCodeInfo_Location) {
+	end := p.prev.end
+	if int32(end.line) != loc.Span[0] {
+		loc.Span = append(loc.Span, int32(end.line))
+	}
+	loc.Span = append(loc.Span, int32(end.col))
+}
+
+func (p *parser) parseFile() {
+	fd := p.file.fd
+	// Like protoc, the location of the file spans its tokens, without
+	// comments, which belong to the first definition.
+	loc := &descriptor.SourceCodeInfo_Location{Span: []int32{int32(p.tok.pos.line), int32(p.tok.pos.col)}}
+	fd.SourceCodeInfo.Location = append(fd.SourceCodeInfo.Location, loc)
+	if p.is("syntax") {
+		p.next()
+		p.expect("=")
+		pos := p.tok.pos
+		p.syntax = p.stringLit()
+		if p.syntax != "proto2" && p.syntax != "proto3" {
+			p.errorf(pos, "unrecognized syntax %q", p.syntax)
+		}
+		p.expect(";")
+		if p.syntax == "proto3" {
+			fd.Syntax = proto.String(p.syntax)
+		}
+	} else if p.is("edition") {
+		p.next()
+		p.expect("=")
+		pos := p.tok.pos
+		if edition := p.stringLit(); edition != "2023" {
+			p.errorf(pos, "edition %q is not supported: only edition \"2023\" is", edition)
+		}
+		p.expect(";")
+		p.syntax = "editions"
+		fd.Syntax = proto.String(p.syntax)
+		fd.XXX_unrecognized = appendVarintField(fd.XXX_unrecognized, fileEditionTag, edition2023)
+	}
+
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.accept(";"):
+		case p.is("import"):
+			p.parseImport()
+		case p.is("package"):
+			pos := p.tok.pos
+			p.next()
+			if fd.Package != nil {
+				p.errorf(pos, "multiple package definitions")
+			}
+			fd.Package = proto.String(p.fullIdent())
+			p.pkg = "." + fd.GetPackage()
+			p.expect(";")
+		case p.is("option"):
+			p.parseOption(p.pkg, ".google.protobuf.FileOptions", func() proto.Message {
+				if fd.Options == nil {
+					fd.Options = &descriptor.FileOptions{}
+				}
+				return fd.Options
+			})
+		case p.is("message"):
+			path := []int32{fileMessageTag, int32(len(fd.MessageType))}
+			fd.MessageType = append(fd.MessageType, p.parseMessage(path, p.pkg))
+		case p.is("enum"):
+			path := []int32{fileEnumTag, int32(len(fd.EnumType))}
+			fd.EnumType = append(fd.EnumType, p.parseEnum(path, p.pkg))
+		case p.is("service"):
+			path := []int32{fileServiceTag, int32(len(fd.Service))}
+			fd.Service = append(fd.Service, p.parseService(path, p.pkg))
+		case p.is("extend"):
+			p.parseExtend(p.pkg, []int32{fileExtensionTag}, &fd.Extension, &fd.MessageType, []int32{fileMessageTag})
+		default:
+			p.errorf(p.tok.pos, "unexpected %s", p.tok)
+		}
+	}
+	p.endLocation(loc)
+}
+
+func (p *parser) parseImport() {
+	fd := p.file.fd
+	pos := p.tok.pos
+	p.next()
+	switch {
+	case p.accept("public"):
+		fd.PublicDependency = append(fd.PublicDependency, int32(len(fd.Dependency)))
+	case p.accept("weak"):
+		fd.WeakDependency = append(fd.WeakDependency, int32(len(fd.Dependency)))
+	}
+	name := p.stringLit()
+	p.expect(";")
+	if _, ok := p.file.importPos[name]; ok {
+		p.errorf(pos, "%q was already imported", name)
+	}
+	p.file.importPos[name] = pos
+	fd.Dependency = append(fd.Dependency, name)
+}
+
+// parseOption parses an option statement, setting an option of the options
+// message named target.
+func (p *parser) parseOption(scope, target string, options func() proto.Message) {
+	p.expect("option")
+	pos := p.tok.pos
+	name := p.optionName()
+	p.expect("=")
+	value := p.optionValue()
+	p.expect(";")
+	p.file.options = append(p.file.options, &pendingOption{
+		pos:     pos,
+		scope:   scope,
+		target:  target,
+		name:    name,
+		value:   value,
+		options: options,
+	})
+}
+
+func (p *parser) optionName() []optionNamePart {
+	var parts []optionNamePart
+	for {
+		pos := p.tok.pos
+		if p.accept("(") {
+			parts = append(parts, optionNamePart{name: p.fullIdent(), ext: true, pos: pos})
+			p.expect(")")
+		} else {
+			parts = append(parts, optionNamePart{name: p.ident(), pos: pos})
+		}
+		if !p.accept(".") {
+			return parts
+		}
+	}
+}
+
+// optionValue parses a constant, or an aggregate value in the protobuf text
+// format.
+func (p *parser) optionValue() *optionValue {
+	v := &optionValue{pos: p.tok.pos}
+	if p.is("{") || p.is("<") {
+		v.kind = valueAggregate
+		v.fields = p.aggregate()
+		return v
+	}
+	if p.accept("-") {
+		v.neg = true
+	} else {
+		p.accept("+")
+	}
+	switch p.tok.kind {
+	case tokenIdent:
+		v.kind = valueIdent
+	case tokenInt:
+		v.kind = valueInt
+	case tokenFloat:
+		v.kind = valueFloat
+	case tokenString:
+		if v.neg {
+			p.errorf(v.pos, "unexpected \"-\"")
+		}
+		v.kind = valueString
+		v.text = p.stringLit()
+		return v
+	default:
+		p.errorf(p.tok.pos, "expected value, found %s", p.tok)
+	}
+	v.text = p.tok.text
+	p.next()
+	return v
+}
+
+func (p *parser) aggregate() []*aggregateField {
+	end := "}"
+	if p.accept("<") {
+		end = ">"
+	} else {
+		p.expect("{")
+	}
+	fields := []*aggregateField{}
+	for !p.accept(end) {
+		f := &aggregateField{pos: p.tok.pos}
+		if p.accept("[") {
+			f.name = p.fullIdent()
+			f.ext = true
+			p.expect("]")
+		} else {
+			f.name = p.ident()
+		}
+		if p.accept(":") {
+			if p.accept("[") {
+				f.value = &optionValue{pos: p.prev.pos, kind: valueList}
+				for !p.accept("]") {
+					f.value.list = append(f.value.list, p.optionValue())
+					if !p.is("]") {
+						p.expect(",")
+					}
+				}
+			} else {
+				f.value = p.optionValue()
+			}
+		} else if p.is("{") || p.is("<") {
+			f.value = p.optionValue()
+		} else {
+			p.errorf(p.tok.pos, "expected \":\", found %s", p.tok)
+		}
+		fields = append(fields, f)
+		if !p.accept(",") {
+			p.accept(";")
+		}
+	}
+	return fields
+}
+
+// parseMessage parses a message defined in scope, at the given path.
+func (p *parser) parseMessage(path []int32, scope string) *descriptor.DescriptorProto {
+	loc := p.startLocation(path)
+	p.expect("message")
+	msg := &descriptor.DescriptorProto{Name: proto.String(p.ident())}
+	p.expect("{")
+	p.trailingComment(loc)
+	p.parseMessageBody(msg, path, scope+"."+msg.GetName())
+	p.endLocation(loc)
+	return msg
+}
+
+// parseMessageBody parses the definitions of msg, up to and including its
+// closing brace.
+func (p *parser) parseMessageBody(msg *descriptor.DescriptorProto, path []int32, name string) {
+	withPath := func(tag int32, index int) []int32 {
+		return append(append([]int32(nil), path...), tag, int32(index))
+	}
+	// Extension ranges ending at max end at the largest int32 in message
+	// sets, as protoc decides from the option as written.
+	messageSet := false
+	var toMax []*descriptor.DescriptorProto_ExtensionRange
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found %s", p.tok)
+		case p.accept(";"):
+		case p.is("option"):
+			p.parseOption(name, ".google.protobuf.MessageOptions", func() proto.Message {
+				if msg.Options == nil {
+					msg.Options = &descriptor.MessageOptions{}
+				}
+				return msg.Options
+			})
+			o := p.file.options[len(p.file.options)-1]
+			if optionNameString(o.name) == "message_set_wire_format" && o.value.kind == valueIdent {
+				messageSet = o.value.text == "true"
+			}
+		case p.is("message"):
+			msg.NestedType = append(msg.NestedType, p.parseMessage(withPath(messageNestedTag, len(msg.NestedType)), name))
+		case p.is("enum"):
+			msg.EnumType = append(msg.EnumType, p.parseEnum(withPath(messageEnumTag, len(msg.EnumType)), name))
+		case p.is("extend"):
+			p.parseExtend(name, append(append([]int32(nil), path...), messageExtensionTag), &msg.Extension, &msg.NestedType, append(append([]int32(nil), path...), messageNestedTag))
+		case p.is("extensions"):
+			toMax = append(toMax, p.parseExtensionRanges(msg, name)...)
+		case p.is("reserved"):
+			p.parseReserved(func(start, end int32) {
+				msg.ReservedRange = append(msg.ReservedRange, &descriptor.DescriptorProto_ReservedRange{Start: proto.Int32(start), End: proto.Int32(end + 1)})
+			}, func(name string) {
+				msg.ReservedName = append(msg.ReservedName, name)
+			}, maxFieldNumber)
+		case p.is("oneof"):
+			p.parseOneof(msg, path, name)
+		case p.is("map"):
+			msg.Field = append(msg.Field, p.parseMapField(msg, withPath(messageFieldTag, len(msg.Field)), name))
+		default:
+			field := p.parseField(withPath(messageFieldTag, len(msg.Field)), name, true, &msg.NestedType, withPath(messageNestedTag, len(msg.NestedType)))
+			msg.Field = append(msg.Field, field)
+		}
+	}
+	if messageSet {
+		for _, r := range toMax {
+			r.End = proto.Int32(math.MaxInt32)
+		}
+	}
+	p.addSyntheticOneofs(msg)
+}
+
+// addSyntheticOneofs adds a oneof holding each proto3 optional field of msg,
+// after the oneofs it declares, as protoc does. Each is named after its
+// field, prefixed with an underscore, and with Xs until the name is unique.
+func (p *parser) addSyntheticOneofs(msg *descriptor.DescriptorProto) {
+	names := map[string]bool{}
+	for _, field := range msg.Field {
+		names[field.GetName()] = true
+	}
+	for _, oneof := range msg.OneofDecl {
+		names[oneof.GetName()] = true
+	}
+	for _, field := range msg.Field {
+		if !p.proto3Optional[field] {
+			continue
+		}
+		name := "_" + field.GetName()
+		for names[name] {
+			name = "X" + name
+		}
+		names[name] = true
+		field.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+		msg.OneofDecl = append(msg.OneofDecl, &descriptor.OneofDescriptorProto{Name: proto.String(name)})
+	}
+}
+
+// parseField parses a field defined in scope. Groups define a message,
+// which is appended to nested, whose location is nestedPath. labelled
+// fields may have a label, while the fields of a oneof may not.
+func (p *parser) parseField(path []int32, scope string, labelled bool, nested *[]*descriptor.DescriptorProto, nestedPath []int32) *descriptor.FieldDescriptorProto {
+	loc := p.startLocation(path)
+	field := &descriptor.FieldDescriptorProto{}
+	if labelled {
+		if p.syntax == "editions" && (p.is("optional") || p.is("required")) {
+			p.errorf(p.tok.pos, "%s labels are not allowed in editions: use the field_presence feature", p.tok.text)
+		}
+		switch {
+		case p.accept("optional"):
+			field.Label = descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+			if p.syntax == "proto3" {
+				// protoc marks proto3 optional fields, and adds a oneof
+				// holding each once the message is parsed.
+				field.XXX_unrecognized = appendVarintField(field.XXX_unrecognized, fieldProto3OptionalTag, 1)
+				p.proto3Optional[field] = true
+			}
+		case p.accept("required"):
+			if p.syntax == "proto3" {
+				p.errorf(p.prev.pos, "required fields are not allowed in proto3")
+			}
+			field.Label = descriptor.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+		case p.accept("repeated"):
+			field.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+	}
+	if field.Label == nil {
+		field.Label = descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	}
+
+	if p.is("group") {
+		if p.syntax == "editions" {
+			p.errorf(p.tok.pos, "groups are not allowed in editions: use the message_encoding feature")
+		}
+		p.parseGroup(field, loc, scope, nested, nestedPath)
+		return field
+	}
+
+	typePos := p.tok.pos
+	typeName := p.fullIdent()
+	if t, ok := scalarTypes[typeName]; ok {
+		field.Type = t.Enum()
+	} else {
+		p.file.refs = append(p.file.refs, &typeRef{
+			pos:   typePos,
+			scope: scope,
+			name:  typeName,
+			set: func(fullName string, sym *symbol) string {
+				switch {
+				case sym.msg != nil:
+					field.Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				case sym.enum != nil:
+					field.Type = descriptor.FieldDescriptorProto_TYPE_ENUM.Enum()
+				default:
+					return "is not a message or enum type"
+				}
+				field.TypeName = proto.String(fullName)
+				return ""
+			},
+		})
+	}
+	field.Name = proto.String(p.ident())
+	field.JsonName = proto.String(jsonName(field.GetName()))
+	p.expect("=")
+	field.Number = proto.Int32(int32(p.intLit(1, maxFieldNumber)))
+	p.parseFieldOptions(field, scope)
+	p.expect(";")
+	p.trailingComment(loc)
+	p.endLocation(loc)
+	return field
+}
+
+// parseGroup parses the rest of a group field, which defines a message of
+// the same name as the group holding its fields.
+func (p *parser) parseGroup(field *descriptor.FieldDescriptorProto, loc *descriptor.SourceCodeInfo_Location, scope string, nested *[]*descriptor.DescriptorProto, nestedPath []int32) {
+	p.expect("group")
+	pos := p.tok.pos
+	name := p.ident()
+	if name[0] < 'A' || name[0] > 'Z' {
+		p.errorf(pos, "group names must start with a capital letter")
+	}
+	field.Name = proto.String(strings.ToLower(name))
+	field.JsonName = proto.String(jsonName(field.GetName()))
+	field.Type = descriptor.FieldDescriptorProto_TYPE_GROUP.Enum()
+	field.TypeName = proto.String(scope + "." + name)
+	p.expect("=")
+	field.Number = proto.Int32(int32(p.intLit(1, maxFieldNumber)))
+	p.parseFieldOptions(field, scope)
+	p.expect("{")
+	p.trailingComment(loc)
+
+	msg := &descriptor.DescriptorProto{Name: proto.String(name)}
+	*nested = append(*nested, msg)
+	p.parseMessageBody(msg, nestedPath, scope+"."+name)
+	p.endLocation(loc)
+}
+
+// parseFieldOptions parses the options between brackets following a field,
+// if any. The default and json_name pseudo-options are set on the field
+// itself.
+func (p *parser) parseFieldOptions(field *descriptor.FieldDescriptorProto, scope string) {
+	if !p.accept("[") {
+		return
+	}
+	for {
+		pos := p.tok.pos
+		name := p.optionName()
+		p.expect("=")
+		value := p.optionValue()
+		switch {
+		case len(name) == 1 && !name[0].ext && name[0].name == "default":
+			if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				p.errorf(pos, "repeated fields can't have default values")
+			}
+			if p.syntax == "proto3" {
+				p.errorf(pos, "explicit default values are not allowed in proto3")
+			}
+			field.DefaultValue = proto.String(p.defaultValue(field, value))
+		case len(name) == 1 && !name[0].ext && name[0].name == "json_name":
+			if value.kind != valueString {
+				p.errorf(value.pos, "json_name must be a string")
+			}
+			field.JsonName = proto.String(value.text)
+		default:
+			p.file.options = append(p.file.options, &pendingOption{
+				pos:    pos,
+				scope:  scope,
+				target: ".google.protobuf.FieldOptions",
+				name:   name,
+				value:  value,
+				options: func() proto.Message {
+					if field.Options == nil {
+						field.Options = &descriptor.FieldOptions{}
+					}
+					return field.Options
+				},
+			})
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("]")
+}
+
+// defaultValue returns the text of a default value as it's stored in
+// descriptors.
+func (p *parser) defaultValue(field *descriptor.FieldDescriptorProto, v *optionValue) string {
+	sign := ""
+	if v.neg {
+		sign = "-"
+	}
+	switch v.kind {
+	case valueString:
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return cEscape(v.text)
+		}
+		return v.text
+	case valueInt:
+		if n, err := strconv.ParseUint(v.text, 0, 64); err == nil {
+			return sign + strconv.FormatUint(n, 10)
+		}
+	case valueFloat:
+		bits := 64
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_FLOAT {
+			bits = 32
+		}
+		if f, err := strconv.ParseFloat(v.text, bits); err == nil {
+			return sign + formatFloat(f, bits)
+		}
+	case valueIdent:
+		return sign + v.text
+	}
+	p.errorf(v.pos, "invalid default value")
+	return ""
+}
+
+// formatFloat formats a default value as protoc does, with the precision of
+// printf's %g the value round-trips with: 6 then 9 digits for floats, 15 then
+// 17 for doubles.
+func formatFloat(f float64, bits int) string {
+	short, long := 15, 17
+	if bits == 32 {
+		short, long = 6, 9
+	}
+	s := strconv.FormatFloat(f, 'g', short, bits)
+	if parsed, err := strconv.ParseFloat(s, bits); err != nil || parsed != f {
+		s = strconv.FormatFloat(f, 'g', long, bits)
+	}
+	return s
+}
+
+// parseMapField parses a map field, defining the entry message it's made of
+// within msg.
+func (p *parser) parseMapField(msg *descriptor.DescriptorProto, path []int32, scope string) *descriptor.FieldDescriptorProto {
+	loc := p.startLocation(path)
+	p.expect("map")
+	p.expect("<")
+	keyPos := p.tok.pos
+	keyType, ok := scalarTypes[p.ident()]
+	if !ok || keyType == descriptor.FieldDescriptorProto_TYPE_DOUBLE || keyType == descriptor.FieldDescriptorProto_TYPE_FLOAT || keyType == descriptor.FieldDescriptorProto_TYPE_BYTES {
+		p.errorf(keyPos, "map keys must be integers, bools or strings")
+	}
+	p.expect(",")
+
+	entryName := ""
+	value := &descriptor.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		JsonName: proto.String("value"),
+		Number:   proto.Int32(2),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	valuePos := p.tok.pos
+	valueType := p.fullIdent()
+	p.expect(">")
+
+	field := &descriptor.FieldDescriptorProto{
+		Name:  proto.String(p.ident()),
+		Label: descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Type:  descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+	}
+	field.JsonName = proto.String(jsonName(field.GetName()))
+	entryName = mapEntryName(field.GetName())
+	field.TypeName = proto.String(scope + "." + entryName)
+	if t, ok := scalarTypes[valueType]; ok {
+		value.Type = t.Enum()
+	} else {
+		p.file.refs = append(p.file.refs, &typeRef{
+			pos:   valuePos,
+			scope: scope + "." + entryName,
+			name:  valueType,
+			set: func(fullName string, sym *symbol) string {
+				switch {
+				case sym.msg != nil:
+					value.Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				case sym.enum != nil:
+					value.Type = descriptor.FieldDescriptorProto_TYPE_ENUM.Enum()
+				default:
+					return "is not a message or enum type"
+				}
+				value.TypeName = proto.String(fullName)
+				return ""
+			},
+		})
+	}
+	p.expect("=")
+	field.Number = proto.Int32(int32(p.intLit(1, maxFieldNumber)))
+	p.parseFieldOptions(field, scope)
+	p.expect(";")
+	p.trailingComment(loc)
+	p.endLocation(loc)
+
+	msg.NestedType = append(msg.NestedType, &descriptor.DescriptorProto{
+		Name: proto.String(entryName),
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:     proto.String("key"),
+				JsonName: proto.String("key"),
+				Number:   proto.Int32(1),
+				Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     keyType.Enum(),
+			},
+			value,
+		},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	})
+	return field
+}
+
+func (p *parser) parseOneof(msg *descriptor.DescriptorProto, path []int32, scope string) {
+	index := int32(len(msg.OneofDecl))
+	loc := p.startLocation(append(append([]int32(nil), path...), messageOneofTag, index))
+	p.expect("oneof")
+	oneof := &descriptor.OneofDescriptorProto{Name: proto.String(p.ident())}
+	msg.OneofDecl = append(msg.OneofDecl, oneof)
+	p.expect("{")
+	p.trailingComment(loc)
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found %s", p.tok)
+		case p.accept(";"):
+		case p.is("option"):
+			p.parseOption(scope, ".google.protobuf.OneofOptions", func() proto.Message {
+				if oneof.Options == nil {
+					oneof.Options = &descriptor.OneofOptions{}
+				}
+				return oneof.Options
+			})
+		default:
+			fieldPath := append(append([]int32(nil), path...), messageFieldTag, int32(len(msg.Field)))
+			nestedPath := append(append([]int32(nil), path...), messageNestedTag, int32(len(msg.NestedType)))
+			field := p.parseField(fieldPath, scope, false, &msg.NestedType, nestedPath)
+			field.OneofIndex = proto.Int32(index)
+			msg.Field = append(msg.Field, field)
+		}
+	}
+	p.endLocation(loc)
+}
+
+// parseExtend parses an extend block in scope, appending the extensions it
+// defines to extensions, at extensionsPath, and the groups it defines to
+// nested, at nestedPath.
+func (p *parser) parseExtend(scope string, extensionsPath []int32, extensions *[]*descriptor.FieldDescriptorProto, nested *[]*descriptor.DescriptorProto, nestedPath []int32) {
+	p.expect("extend")
+	pos := p.tok.pos
+	extendee := p.fullIdent()
+	p.expect("{")
+	for !p.accept("}") {
+		if p.tok.kind == tokenEOF {
+			p.errorf(p.tok.pos, "expected \"}\", found %s", p.tok)
+		}
+		if p.accept(";") {
+			continue
+		}
+		path := append(append([]int32(nil), extensionsPath...), int32(len(*extensions)))
+		groupPath := append(append([]int32(nil), nestedPath...), int32(len(*nested)))
+		field := p.parseField(path, scope, true, nested, groupPath)
+		*extensions = append(*extensions, field)
+		p.file.refs = append(p.file.refs, &typeRef{
+			pos:   pos,
+			scope: scope,
+			name:  extendee,
+			set: func(fullName string, sym *symbol) string {
+				if sym.msg == nil {
+					return "is not a message type"
+				}
+				field.Extendee = proto.String(fullName)
+				return ""
+			},
+		})
+	}
+}
+
+// parseExtensionRanges parses an extensions statement, adding its ranges to
+// msg. It returns the ranges ending at max.
+func (p *parser) parseExtensionRanges(msg *descriptor.DescriptorProto, scope string) []*descriptor.DescriptorProto_ExtensionRange {
+	p.expect("extensions")
+	var ranges, toMax []*descriptor.DescriptorProto_ExtensionRange
+	for {
+		start := int32(p.intLit(1, maxFieldNumber))
+		end := start
+		toEnd := false
+		if p.accept("to") {
+			if p.accept("max") {
+				end = maxFieldNumber
+				toEnd = true
+			} else {
+				end = int32(p.intLit(1, maxFieldNumber))
+			}
+		}
+		r := &descriptor.DescriptorProto_ExtensionRange{Start: proto.Int32(start), End: proto.Int32(end + 1)}
+		ranges = append(ranges, r)
+		if toEnd {
+			toMax = append(toMax, r)
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.accept("[") {
+		for {
+			pos := p.tok.pos
+			name := p.optionName()
+			p.expect("=")
+			value := p.optionValue()
+			for _, r := range ranges {
+				r := r
+				p.file.options = append(p.file.options, &pendingOption{
+					pos:    pos,
+					scope:  scope,
+					target: ".google.protobuf.ExtensionRangeOptions",
+					name:   name,
+					value:  value,
+					options: func() proto.Message {
+						if r.Options == nil {
+							r.Options = &descriptor.ExtensionRangeOptions{}
+						}
+						return r.Options
+					},
+				})
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect("]")
+	}
+	p.expect(";")
+	msg.ExtensionRange = append(msg.ExtensionRange, ranges...)
+	return toMax
+}
+
+// parseReserved parses a reserved statement, passing each inclusive range of
+// numbers to addRange and each name to addName.
+func (p *parser) parseReserved(addRange func(start, end int32), addName func(name string), max int64) {
+	p.expect("reserved")
+	if p.tok.kind == tokenString {
+		for {
+			addName(p.stringLit())
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect(";")
+		return
+	}
+	min := int64(1)
+	if max != maxFieldNumber {
+		min = math.MinInt32
+	}
+	for {
+		start := int32(p.intLit(min, max))
+		end := start
+		if p.accept("to") {
+			if p.accept("max") {
+				end = int32(max)
+			} else {
+				end = int32(p.intLit(min, max))
+			}
+		}
+		addRange(start, end)
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(";")
+}
+
+func (p *parser) parseEnum(path []int32, scope string) *descriptor.EnumDescriptorProto {
+	loc := p.startLocation(path)
+	p.expect("enum")
+	enum := &descriptor.EnumDescriptorProto{Name: proto.String(p.ident())}
+	p.expect("{")
+	p.trailingComment(loc)
+	name := scope + "." + enum.GetName()
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found %s", p.tok)
+		case p.accept(";"):
+		case p.is("option"):
+			p.parseOption(name, ".google.protobuf.EnumOptions", func() proto.Message {
+				if enum.Options == nil {
+					enum.Options = &descriptor.EnumOptions{}
+				}
+				return enum.Options
+			})
+		case p.is("reserved"):
+			p.parseReserved(func(start, end int32) {
+				enum.ReservedRange = append(enum.ReservedRange, &descriptor.EnumDescriptorProto_EnumReservedRange{Start: proto.Int32(start), End: proto.Int32(end)})
+			}, func(name string) {
+				enum.ReservedName = append(enum.ReservedName, name)
+			}, math.MaxInt32)
+		default:
+			valuePath := append(append([]int32(nil), path...), enumValueTag, int32(len(enum.Value)))
+			enum.Value = append(enum.Value, p.parseEnumValue(valuePath, name))
+		}
+	}
+	p.endLocation(loc)
+	if len(enum.Value) == 0 {
+		p.errorf(p.prev.pos, "enums must define at least one value")
+	}
+	return enum
+}
+
+func (p *parser) parseEnumValue(path []int32, scope string) *descriptor.EnumValueDescriptorProto {
+	loc := p.startLocation(path)
+	value := &descriptor.EnumValueDescriptorProto{Name: proto.String(p.ident())}
+	p.expect("=")
+	value.Number = proto.Int32(int32(p.intLit(math.MinInt32, math.MaxInt32)))
+	if p.accept("[") {
+		for {
+			pos := p.tok.pos
+			name := p.optionName()
+			p.expect("=")
+			p.file.options = append(p.file.options, &pendingOption{
+				pos:    pos,
+				scope:  scope,
+				target: ".google.protobuf.EnumValueOptions",
+				name:   name,
+				value:  p.optionValue(),
+				options: func() proto.Message {
+					if value.Options == nil {
+						value.Options = &descriptor.EnumValueOptions{}
+					}
+					return value.Options
+				},
+			})
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect("]")
+	}
+	p.expect(";")
+	p.trailingComment(loc)
+	p.endLocation(loc)
+	return value
+}
+
+func (p *parser) parseService(path []int32, scope string) *descriptor.ServiceDescriptorProto {
+	loc := p.startLocation(path)
+	p.expect("service")
+	service := &descriptor.ServiceDescriptorProto{Name: proto.String(p.ident())}
+	p.expect("{")
+	p.trailingComment(loc)
+	name := scope + "." + service.GetName()
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.pos, "expected \"}\", found %s", p.tok)
+		case p.accept(";"):
+		case p.is("option"):
+			p.parseOption(name, ".google.protobuf.ServiceOptions", func() proto.Message {
+				if service.Options == nil {
+					service.Options = &descriptor.ServiceOptions{}
+				}
+				return service.Options
+			})
+		default:
+			methodPath := append(append([]int32(nil), path...), serviceMethodTag, int32(len(service.Method)))
+			service.Method = append(service.Method, p.parseMethod(methodPath, scope, name))
+		}
+	}
+	p.endLocation(loc)
+	return service
+}
+
+// parseMethod parses a method of the service named serviceName. Its input
+// and output types are resolved in scope, the scope of the service.
+func (p *parser) parseMethod(path []int32, scope, serviceName string) *descriptor.MethodDescriptorProto {
+	loc := p.startLocation(path)
+	p.expect("rpc")
+	method := &descriptor.MethodDescriptorProto{Name: proto.String(p.ident())}
+	// messageType parses a parenthesized message type, which may be streamed,
+	// and passes its fully-qualified name to set once it's resolved.
+	messageType := func(set func(fullName string)) (streaming bool) {
+		p.expect("(")
+		pos := p.tok.pos
+		name := ""
+		if p.accept("stream") {
+			switch {
+			case p.is(")"):
+				// stream is also a valid message name.
+				name = "stream"
+			case p.is("."):
+				name = "stream" + p.fullIdent()
+			default:
+				streaming = true
+				pos = p.tok.pos
+			}
+		}
+		if name == "" {
+			name = p.fullIdent()
+		}
+		p.expect(")")
+		p.file.refs = append(p.file.refs, &typeRef{
+			pos:   pos,
+			scope: scope,
+			name:  name,
+			set: func(fullName string, sym *symbol) string {
+				if sym.msg == nil {
+					return "is not a message type"
+				}
+				set(fullName)
+				return ""
+			},
+		})
+		return streaming
+	}
+	if messageType(func(fullName string) { method.InputType = proto.String(fullName) }) {
+		method.ClientStreaming = proto.Bool(true)
+	}
+	p.expect("returns")
+	if messageType(func(fullName string) { method.OutputType = proto.String(fullName) }) {
+		method.ServerStreaming = proto.Bool(true)
+	}
+
+	if p.accept("{") {
+		p.trailingComment(loc)
+		for !p.accept("}") {
+			switch {
+			case p.tok.kind == tokenEOF:
+				p.errorf(p.tok.pos, "expected \"}\", found %s", p.tok)
+			case p.accept(";"):
+			default:
+				p.parseOption(serviceName, ".google.protobuf.MethodOptions", func() proto.Message {
+					if method.Options == nil {
+						method.Options = &descriptor.MethodOptions{}
+					}
+					return method.Options
+				})
+			}
+		}
+	} else {
+		p.expect(";")
+		p.trailingComment(loc)
+	}
+	p.endLocation(loc)
+	return method
+}
+
+// jsonName returns the JSON name protoc derives from a field name.
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// mapEntryName returns the name of the message protoc defines for the
+// entries of a map field.
+func mapEntryName(field string) string {
+	var b strings.Builder
+	upper := true
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteByte(c)
+	}
+	return b.String() + "Entry"
+}
+
+// cEscape escapes bytes the way protoc stores the default values of bytes
+// fields.
+func cEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				b.WriteString(`\` + strconv.FormatInt(int64(c)>>6, 8) + strconv.FormatInt(int64(c)>>3&7, 8) + strconv.FormatInt(int64(c)&7, 8))
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// appendVarintField appends the varint field number, holding v, to the
+// encoded message b.
+func appendVarintField(b []byte, number int32, v uint64) []byte {
+	b = append(b, proto.EncodeVarint(uint64(number)<<3|proto.WireVarint)...)
+	return append(b, proto.EncodeVarint(v)...)
+}
@@ -0,0 +1,424 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/thesilentg/proto-to-insomnia/presence"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "missing semicolon",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\npackage bad;\nmessage Bad { string name = 1 }\n"},
+			want:  `bad.proto:3:31: expected ";", found "}"`,
+		},
+		{
+			name:  "unknown syntax",
+			files: map[string]string{"bad.proto": "syntax = \"proto4\";\n"},
+			want:  `bad.proto:1:10: unrecognized syntax "proto4"`,
+		},
+		{
+			name:  "unresolved import",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\nimport \"missing.proto\";\n"},
+			want:  `bad.proto:2:1: "missing.proto" was not found in the import paths`,
+		},
+		{
+			name: "import cycle",
+			files: map[string]string{
+				"bad.proto":   "syntax = \"proto3\";\nimport \"cycle.proto\";\n",
+				"cycle.proto": "syntax = \"proto3\";\nimport \"bad.proto\";\n",
+			},
+			want: `cycle.proto:2:1: import cycle: "bad.proto" imports cycle.proto`,
+		},
+		{
+			name:  "unresolved type",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\nmessage A { Unknown u = 1; }\n"},
+			want:  `bad.proto:2:13: "Unknown" is not defined`,
+		},
+		{
+			name:  "duplicate definition",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\nmessage A {}\nmessage A {}\n"},
+			want:  `bad.proto:3:1: "A" is already defined`,
+		},
+		{
+			name:  "unknown option",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\nmessage A { string a = 1 [(nope) = 1]; }\n"},
+			want:  `bad.proto:2:27: option "(nope)" is unknown`,
+		},
+		{
+			name:  "invalid option value",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\nmessage A { string a = 1 [deprecated = \"yes\"]; }\n"},
+			want:  `bad.proto:2:40: deprecated must be true or false`,
+		},
+		{
+			name:  "proto3 default",
+			files: map[string]string{"bad.proto": "syntax = \"proto3\";\nmessage A { string a = 1 [default = \"x\"]; }\n"},
+			want:  `bad.proto:2:27: explicit default values are not allowed in proto3`,
+		},
+		{
+			name:  "lowercase group",
+			files: map[string]string{"bad.proto": "syntax = \"proto2\";\nmessage A { optional group g = 1 {} }\n"},
+			want:  `bad.proto:2:28: group names must start with a capital letter`,
+		},
+		{
+			name:  "unsupported edition",
+			files: map[string]string{"bad.proto": "edition = \"2024\";\n"},
+			want:  `bad.proto:1:11: edition "2024" is not supported: only edition "2023" is`,
+		},
+		{
+			name:  "label in editions",
+			files: map[string]string{"bad.proto": "edition = \"2023\";\nmessage A { optional string a = 1; }\n"},
+			want:  `bad.proto:2:13: optional labels are not allowed in editions: use the field_presence feature`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parseFiles(t, c.files, "bad.proto")
+			if err == nil || err.Error() != c.want {
+				t.Errorf("got error %v, want %s", err, c.want)
+			}
+		})
+	}
+}
+
+func TestMissingFile(t *testing.T) {
+	_, err := parseFiles(t, nil, "missing.proto")
+	if want := "missing.proto: file not found in the import paths"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestImports(t *testing.T) {
+	files := mustParseFiles(t, map[string]string{
+		"a.proto":      "syntax = \"proto3\";\npackage a;\nimport \"b/b.proto\";\nimport \"google/protobuf/timestamp.proto\";\nmessage A { b.B b = 1; google.protobuf.Timestamp at = 2; }\n",
+		"b/b.proto":    "syntax = \"proto3\";\npackage b;\nimport public \"b/pub.proto\";\nmessage B { Pub pub = 1; }\n",
+		"b/pub.proto":  "syntax = \"proto3\";\npackage b;\nmessage Pub {}\n",
+		"unused.proto": "syntax = \"proto3\";\n",
+	}, "a.proto")
+	var names []string
+	for _, file := range files {
+		names = append(names, file.GetName())
+	}
+	if want := []string{"b/pub.proto", "b/b.proto", "google/protobuf/timestamp.proto", "a.proto"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+	b := files[1]
+	if got := b.GetPublicDependency(); len(got) != 1 || got[0] != 0 {
+		t.Errorf("got public dependencies %v, want [0]", got)
+	}
+	a := files[3].MessageType[0]
+	if got := a.Field[0].GetTypeName(); got != ".b.B" {
+		t.Errorf("got type %s, want .b.B", got)
+	}
+	if got := a.Field[1].GetTypeName(); got != ".google.protobuf.Timestamp" {
+		t.Errorf("got type %s, want .google.protobuf.Timestamp", got)
+	}
+}
+
+// TestInsomniaOptions checks that the built-in insomnia/options.proto is the
+// same as proto/insomnia/options.proto, and that it's imported without being
+// on the import path.
+func TestInsomniaOptions(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("..", "proto", "insomnia", "options.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != insomniaOptions {
+		t.Error("the built-in insomnia/options.proto differs from proto/insomnia/options.proto")
+	}
+
+	files := mustParseFiles(t, map[string]string{
+		"a.proto": "syntax = \"proto3\";\npackage a;\nimport \"insomnia/options.proto\";\nservice S { option (insomnia.service).path_prefix = \"/api\"; }\n",
+	}, "a.proto")
+	var names []string
+	for _, file := range files {
+		names = append(names, file.GetName())
+	}
+	if want := []string{"google/protobuf/descriptor.proto", "insomnia/options.proto", "a.proto"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+}
+
+func TestNestedTypes(t *testing.T) {
+	file := mustParseFile(t, `syntax = "proto3";
+package nested;
+message Outer {
+  message Inner {
+    enum Kind { KIND_UNSPECIFIED = 0; KIND_A = 1; }
+    Kind kind = 1;
+  }
+  Inner inner = 1;
+  Inner.Kind kind = 2;
+  repeated Outer children = 3;
+}
+`)
+	outer := file.MessageType[0]
+	inner := outer.NestedType[0]
+	if inner.GetName() != "Inner" || inner.EnumType[0].GetName() != "Kind" {
+		t.Fatalf("got nested types %v", outer.NestedType)
+	}
+	for _, c := range []struct {
+		field *descriptor.FieldDescriptorProto
+		typ   descriptor.FieldDescriptorProto_Type
+		name  string
+	}{
+		{inner.Field[0], descriptor.FieldDescriptorProto_TYPE_ENUM, ".nested.Outer.Inner.Kind"},
+		{outer.Field[0], descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".nested.Outer.Inner"},
+		{outer.Field[1], descriptor.FieldDescriptorProto_TYPE_ENUM, ".nested.Outer.Inner.Kind"},
+		{outer.Field[2], descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".nested.Outer"},
+	} {
+		if c.field.GetType() != c.typ || c.field.GetTypeName() != c.name {
+			t.Errorf("field %s: got %s %s, want %s %s", c.field.GetName(), c.field.GetType(), c.field.GetTypeName(), c.typ, c.name)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	file := mustParseFile(t, `syntax = "proto3";
+package options;
+import "google/protobuf/descriptor.proto";
+option go_package = "example.com/options";
+option java_multiple_files = true;
+extend google.protobuf.FieldOptions {
+  Label label = 50000;
+}
+message Label {
+  string text = 1;
+  int32 count = 2;
+}
+message A {
+  option deprecated = true;
+  string a = 1 [json_name = "alpha", (label) = {text: "A" count: 2}];
+  repeated int32 b = 2 [packed = false];
+}
+`)
+	if got := file.GetOptions().GetGoPackage(); got != "example.com/options" {
+		t.Errorf("got go_package %q", got)
+	}
+	if !file.GetOptions().GetJavaMultipleFiles() {
+		t.Error("java_multiple_files isn't set")
+	}
+	msg := file.MessageType[1]
+	if !msg.GetOptions().GetDeprecated() {
+		t.Error("deprecated isn't set")
+	}
+	if got := msg.Field[0].GetJsonName(); got != "alpha" {
+		t.Errorf("got json_name %q, want alpha", got)
+	}
+	if msg.Field[1].GetOptions() == nil || msg.Field[1].GetOptions().Packed == nil || msg.Field[1].GetOptions().GetPacked() {
+		t.Error("packed isn't set to false")
+	}
+
+	// The custom option is encoded as an unknown extension of FieldOptions.
+	b, err := proto.Marshal(msg.Field[0].GetOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x82, 0xb5, 0x18, 5, // Field 50000, 5 bytes.
+		0x0a, 1, 'A', // text
+		0x10, 2, // count
+	}
+	if string(b) != string(want) {
+		t.Errorf("got options % x, want % x", b, want)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	file := mustParseFile(t, `syntax = "proto2";
+package groups;
+message Search {
+  repeated group Result = 1 {
+    required string url = 2;
+  }
+}
+`)
+	msg := file.MessageType[0]
+	field := msg.Field[0]
+	if field.GetName() != "result" || field.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP || field.GetTypeName() != ".groups.Search.Result" {
+		t.Errorf("got group field %v", field)
+	}
+	if len(msg.NestedType) != 1 || msg.NestedType[0].GetName() != "Result" || msg.NestedType[0].Field[0].GetLabel() != descriptor.FieldDescriptorProto_LABEL_REQUIRED {
+		t.Errorf("got nested types %v", msg.NestedType)
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	file := mustParseFile(t, `syntax = "proto2";
+package extensions;
+message Base {
+  extensions 100 to 199, 300;
+}
+extend Base {
+  optional int32 count = 100;
+}
+message Holder {
+  extend Base {
+    optional string note = 101;
+  }
+}
+`)
+	ranges := file.MessageType[0].ExtensionRange
+	if len(ranges) != 2 || ranges[0].GetStart() != 100 || ranges[0].GetEnd() != 200 || ranges[1].GetStart() != 300 || ranges[1].GetEnd() != 301 {
+		t.Errorf("got extension ranges %v", ranges)
+	}
+	for _, ext := range []*descriptor.FieldDescriptorProto{file.Extension[0], file.MessageType[1].Extension[0]} {
+		if ext.GetExtendee() != ".extensions.Base" {
+			t.Errorf("extension %s: got extendee %s, want .extensions.Base", ext.GetName(), ext.GetExtendee())
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	file := mustParseFile(t, `syntax = "proto3";
+package maps;
+message Item {}
+message Catalog {
+  map<string, Item> item_by_id = 1;
+}
+`)
+	msg := file.MessageType[1]
+	field := msg.Field[0]
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || field.GetTypeName() != ".maps.Catalog.ItemByIdEntry" || field.GetJsonName() != "itemById" {
+		t.Errorf("got map field %v", field)
+	}
+	entry := msg.NestedType[0]
+	if entry.GetName() != "ItemByIdEntry" || !entry.GetOptions().GetMapEntry() {
+		t.Fatalf("got entry %v", entry)
+	}
+	key, value := entry.Field[0], entry.Field[1]
+	if key.GetName() != "key" || key.GetNumber() != 1 || key.GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+		t.Errorf("got key %v", key)
+	}
+	if value.GetName() != "value" || value.GetNumber() != 2 || value.GetTypeName() != ".maps.Item" {
+		t.Errorf("got value %v", value)
+	}
+}
+
+func TestOneofs(t *testing.T) {
+	file := mustParseFile(t, `syntax = "proto3";
+package oneofs;
+message A {
+  oneof choice {
+    string text = 1;
+    int32 number = 2;
+  }
+  optional string note = 3;
+  string plain = 4;
+}
+`)
+	msg := file.MessageType[0]
+	var decls []string
+	for _, oneof := range msg.OneofDecl {
+		decls = append(decls, oneof.GetName())
+	}
+	if want := []string{"choice", "_note"}; !reflect.DeepEqual(decls, want) {
+		t.Errorf("got oneofs %q, want %q", decls, want)
+	}
+	for i, want := range []int32{0, 0, 1, -1} {
+		field := msg.Field[i]
+		if got := int32(-1); field.OneofIndex != nil {
+			got = field.GetOneofIndex()
+			if got != want {
+				t.Errorf("field %s: got oneof %d, want %d", field.GetName(), got, want)
+			}
+		} else if want != -1 {
+			t.Errorf("field %s isn't in oneof %d", field.GetName(), want)
+		}
+	}
+	if ix := presence.New([]*descriptor.FileDescriptorProto{file}); ix.Of(msg.Field[2]) != presence.Explicit || ix.Of(msg.Field[3]) != presence.Implicit {
+		t.Error("note should be the only proto3 optional field")
+	}
+}
+
+func TestEditions(t *testing.T) {
+	file := mustParseFile(t, `edition = "2023";
+package editions;
+option features.field_presence = IMPLICIT;
+message A {
+  string implicit = 1;
+  string explicit = 2 [features.field_presence = EXPLICIT];
+  string required = 3 [features.field_presence = LEGACY_REQUIRED];
+  A child = 4;
+}
+`)
+	if file.GetSyntax() != "editions" || !presence.Editions(file) {
+		t.Fatalf("got syntax %q, want editions", file.GetSyntax())
+	}
+	ix := presence.New([]*descriptor.FileDescriptorProto{file})
+	for i, want := range []presence.Presence{presence.Implicit, presence.Explicit, presence.Required, presence.Explicit} {
+		if got := ix.Of(file.MessageType[0].Field[i]); got != want {
+			t.Errorf("field %s: got presence %d, want %d", file.MessageType[0].Field[i].GetName(), got, want)
+		}
+	}
+}
+
+// TestFileLocation checks that the first location spans the file's tokens,
+// as protoc's does, leaving the comments to the first definition.
+func TestFileLocation(t *testing.T) {
+	file := mustParseFile(t, "// Comment.\nsyntax = \"proto3\";\nmessage A {}\n")
+	loc := file.GetSourceCodeInfo().GetLocation()[0]
+	if len(loc.Path) != 0 || !reflect.DeepEqual(loc.Span, []int32{1, 0, 2, 12}) || loc.LeadingComments != nil {
+		t.Errorf("got file location %v, want path [] and span [1 0 2 12] without comments", loc)
+	}
+}
+
+// parseFiles writes files to a directory and parses the file named name
+// from it.
+func parseFiles(t *testing.T, files map[string]string, name string) ([]*descriptor.FileDescriptorProto, error) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "protoparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for path, src := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Parser{ImportPaths: []string{dir}}.ParseFiles(name)
+}
+
+func mustParseFiles(t *testing.T, files map[string]string, name string) []*descriptor.FileDescriptorProto {
+	t.Helper()
+	parsed, err := parseFiles(t, files, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// mustParseFile parses a single file holding src.
+func mustParseFile(t *testing.T, src string) *descriptor.FileDescriptorProto {
+	t.Helper()
+	files := mustParseFiles(t, map[string]string{"test.proto": src}, "test.proto")
+	return files[len(files)-1]
+}
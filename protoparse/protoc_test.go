@@ -0,0 +1,73 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package protoparse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// TestMatchesProtoc checks that files parse into the descriptors protoc
+// passes to plugins. testdata/protoc holds the test files of
+// github.com/golang/protobuf v1.3.2, and descriptors.pb the descriptors
+// protoc produced for them, as embedded in the Go files generated from them,
+// which leave out source code info. The well-known types are parsed from the
+// built-in sources.
+func TestMatchesProtoc(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "protoc", "descriptors.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range set.File {
+		files, err := Parser{ImportPaths: []string{filepath.Join("testdata", "protoc")}}.ParseFiles(want.GetName())
+		if err != nil {
+			t.Errorf("%s: %v", want.GetName(), err)
+			continue
+		}
+		got := files[len(files)-1]
+		got.SourceCodeInfo = nil
+		if !proto.Equal(got, want) {
+			t.Errorf("%s differs from protoc's descriptor\n%s", want.GetName(), firstDifference(proto.MarshalTextString(want), proto.MarshalTextString(got)))
+		}
+	}
+}
+
+// firstDifference describes the first line at which want and got differ.
+func firstDifference(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 Twitch Interactive, Inc.  All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the License is
+// located at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// or in the "license" file accompanying this file. This file is distributed on
+// an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package protoparse parses .proto files into the descriptors protoc passes
+// to plugins, so that plugins can be run without protoc installed.
+//
+// It supports the proto2 and proto3 syntaxes, including options, extensions
+// and the comments held by source code info. The well-known types under
+// google/protobuf and the options of protoc-gen-insomniaenv, in
+// insomnia/options.proto, are built in, so they can be imported without being
+// on the import path.
+package protoparse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const descriptorProtoName = "google/protobuf/descriptor.proto"
+
+// Parser parses .proto files, and the files they import.
+type Parser struct {
+	// ImportPaths are the directories files and imports are looked up in,
+	// like the --proto_path flags of protoc. Defaults to the current
+	// directory.
+	ImportPaths []string
+}
+
+func (p Parser) importPaths() []string {
+	if len(p.ImportPaths) == 0 {
+		return []string{"."}
+	}
+	return p.ImportPaths
+}
+
+// ImportName returns the name of the file at path, relative to the first
+// import path it's within, which is how protoc names files.
+func (p Parser) ImportName(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range p.importPaths() {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(absDir, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not within an import path", path)
+}
+
+// ParseFiles parses the files named by filenames, which are relative to the
+// import paths, along with the files they import. It returns every file
+// after the files it imports, the order protoc passes them to plugins in.
+func (p Parser) ParseFiles(filenames ...string) ([]*descriptor.FileDescriptorProto, error) {
+	const (
+		loading = iota + 1
+		loaded
+	)
+	state := map[string]int{}
+	var files []*descriptor.FileDescriptorProto
+	var parsed []*parsedFile
+
+	var load func(name string, importer *parsedFile) error
+	load = func(name string, importer *parsedFile) error {
+		importError := func(format string, args ...interface{}) error {
+			return newLexer(importer.fd.GetName(), "").errorf(importer.importPos[name], format, args...)
+		}
+		switch state[name] {
+		case loading:
+			return importError("import cycle: %q imports %s", name, importer.fd.GetName())
+		case loaded:
+			return nil
+		}
+		state[name] = loading
+
+		src, ok, err := p.readFile(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if name != descriptorProtoName {
+				if importer == nil {
+					return fmt.Errorf("%s: file not found in the import paths", name)
+				}
+				return importError("%q was not found in the import paths", name)
+			}
+			fd, err := descriptorProto()
+			if err != nil {
+				return err
+			}
+			state[name] = loaded
+			files = append(files, fd)
+			parsed = append(parsed, nil)
+			return nil
+		}
+
+		pf, err := parse(name, src)
+		if err != nil {
+			return err
+		}
+		for _, dep := range pf.fd.Dependency {
+			if err := load(dep, pf); err != nil {
+				return err
+			}
+		}
+		state[name] = loaded
+		files = append(files, pf.fd)
+		parsed = append(parsed, pf)
+		return nil
+	}
+	for _, name := range filenames {
+		if err := load(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	l := newLinker()
+	if state[descriptorProtoName] == 0 {
+		// Options are set on the messages defined by descriptor.proto, even
+		// when it isn't imported.
+		fd, err := descriptorProto()
+		if err != nil {
+			return nil, err
+		}
+		l.addFile(fd)
+	}
+	for _, fd := range files {
+		l.addFile(fd)
+	}
+	if len(l.errs) == 0 {
+		for _, pf := range parsed {
+			if pf != nil {
+				l.link(pf)
+			}
+		}
+	}
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+	return files, nil
+}
+
+// readFile returns the contents of the file named name from the import paths,
+// or from the built-in well-known types and options.
+func (p Parser) readFile(name string) (string, bool, error) {
+	for _, dir := range p.importPaths() {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return string(b), true, nil
+	}
+	if name == insomniaOptionsName {
+		return insomniaOptions, true, nil
+	}
+	src, ok := wellKnownTypes[name]
+	return src, ok, nil
+}
+
+// descriptorProto returns the descriptor of google/protobuf/descriptor.proto
+// registered by the descriptor package, which defines the standard options,
+// with the features of editions added.
+func descriptorProto() (*descriptor.FileDescriptorProto, error) {
+	r, err := gzip.NewReader(bytes.NewReader(proto.FileDescriptor(descriptorProtoName)))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fd := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return nil, err
+	}
+	addFeatures(fd)
+	return fd, nil
+}
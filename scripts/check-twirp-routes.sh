@@ -0,0 +1,35 @@
+#!/usr/bin/env bash
+# Checks that the Twirp paths protoc-gen-insomniaenv generates match the routes
+# of the servers protoc-gen-twirp generates for the same proto files, using the
+# version of Twirp in _tools. Arguments are passed to protoc, for example:
+#
+#     scripts/check-twirp-routes.sh -I example example/service.proto
+#
+# Services using the (insomnia.service) path_prefix option are expected to
+# differ, as protoc-gen-twirp always mounts services under /twirp.
+set -euo pipefail
+
+root=$(cd "$(dirname "$0")/.." && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+GOPATH="$root/_tools" GO111MODULE=off GOFLAGS= go build -o "$tmp/protoc-gen-twirp" github.com/twitchtv/twirp/protoc-gen-twirp
+(cd "$root" && go build -mod=vendor -o "$tmp/protoc-gen-insomniaenv" ./protoc-gen-insomniaenv)
+
+mkdir "$tmp/out"
+protoc \
+	--plugin="$tmp/protoc-gen-twirp" \
+	--plugin="$tmp/protoc-gen-insomniaenv" \
+	--twirp_out="$tmp/out" \
+	--insomniaenv_out=protocol=twirp,routes=true:"$tmp/out" \
+	"$@"
+
+find "$tmp/out" -name '*.twirp.go' -exec grep -hoE 'case "/twirp/[^"]*"' {} + |
+	sed -E 's/^case "(.*)"$/POST \1/' | sort >"$tmp/twirp.txt"
+find "$tmp/out" -name '*-routes.txt' -exec cat {} + | sort >"$tmp/insomniaenv.txt"
+
+if ! diff -u "$tmp/twirp.txt" "$tmp/insomniaenv.txt"; then
+	echo "generated paths differ from protoc-gen-twirp's routes" >&2
+	exit 1
+fi
+echo "all $(wc -l <"$tmp/twirp.txt" | tr -d ' ') routes match"